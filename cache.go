@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/clients"
+	"handler/function/config"
+)
+
+// cachedConfig is the config shared between invocations
+var cachedConfig configCache
+
+// configCache struct to represent the parsed config file and its storage
+// clients, reloaded when the file changes (e.g. when Kubernetes rotates the
+// mounted secret)
+type configCache struct {
+	mu      sync.Mutex
+	path    string
+	info    os.FileInfo
+	cfg     *config.Config
+	clients *clientCache
+}
+
+// load returns the config in configPath and its storage clients, reading it
+// again only if the file has been modified since the last load
+func (cc *configCache) load(configPath string) (*config.Config, *clientCache, error) {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return nil, nil, errors.New("Error opening config file")
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.cfg != nil && cc.path == configPath && sameFile(cc.info, info) {
+		return cc.cfg, cc.clients, nil
+	}
+
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	cc.path = configPath
+	cc.info = info
+	cc.cfg = cfg
+	cc.clients = newClientCache(cfg)
+	return cc.cfg, cc.clients, nil
+}
+
+// sameFile checks if two file infos describe the same unmodified file.
+// Rotated secrets are replaced with new files, so the inode is also compared
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// clientCache struct to represent the storage clients of a config, created
+// when they are first used and shared between concurrent invocations
+type clientCache struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	clients map[string]clients.StorageClient
+}
+
+func newClientCache(cfg *config.Config) *clientCache {
+	return &clientCache{
+		cfg:     cfg,
+		clients: make(map[string]clients.StorageClient),
+	}
+}
+
+// Get returns the client of a storage provider, creating it if needed
+func (cc *clientCache) Get(name string) (clients.StorageClient, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if client, ok := cc.clients[name]; ok {
+		return client, nil
+	}
+	provider, ok := cc.cfg.StorageProviders[name]
+	if !ok {
+		return nil, errors.New("Undefined storage provider '" + name + "'")
+	}
+	client, err := clients.GetClient(&provider)
+	if err != nil {
+		return nil, errors.New("Error creating client for storage provider '" + name + "': " + err.Error())
+	}
+	cc.clients[name] = client
+	return client, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigCache(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	configPath := filepath.Join(dir, "secrets", "config")
+
+	var cache configCache
+	cfg, clientCache, err := cache.load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := clientCache.Get("input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientCache.Get("missing"); err == nil {
+		t.Error("Error getting client of undefined storage provider")
+	}
+
+	// The config and the clients are reused while the file isn't modified
+	cachedCfg, cachedClients, err := cache.load(configPath)
+	if err != nil || cachedCfg != cfg || cachedClients != clientCache {
+		t.Error("Error reusing cached config")
+	}
+	if cachedClient, _ := cachedClients.Get("input"); cachedClient != client {
+		t.Error("Error reusing cached client")
+	}
+
+	// Rotated secrets are replaced with new files
+	newConfig := `{
+		"storages": {"local": [{"name": "input", "directory": "` + filepath.Join(dir, "input") + `"}]},
+		"output": [{"storage_name": "input", "path": "copies"}]
+	}`
+	tmpPath := filepath.Join(dir, "secrets", "config.new")
+	if err := ioutil.WriteFile(tmpPath, []byte(newConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		t.Fatal(err)
+	}
+	reloadedCfg, reloadedClients, err := cache.load(configPath)
+	if err != nil || reloadedCfg == cfg || reloadedClients == clientCache || len(reloadedCfg.StorageProviders) != 1 {
+		t.Error("Error reloading modified config")
+	}
+
+	// Invalid configs aren't cached
+	if err := ioutil.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cache.load(configPath); err == nil {
+		t.Error("Error reporting invalid config")
+	}
+	if _, _, err := cache.load(configPath); err == nil {
+		t.Error("Error reporting cached invalid config")
+	}
+}
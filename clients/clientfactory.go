@@ -20,7 +20,9 @@
 package clients
 
 import (
+	"context"
 	"errors"
+	"io"
 	"strings"
 
 	//"github.com/grycap/multi-out-faas/config"
@@ -29,18 +31,90 @@ import (
 
 var errInvalidProvider = errors.New("Invalid provider")
 
-// StorageClient interface for all storage clients
+var errStatNotSupported = errors.New("The storage client can't read the attributes of files")
+
+// StorageClient interface for all storage clients.
+// Files are transferred as streams, so they don't need to be stored locally.
+// The metadata of uploaded files is optional (nil), and providers without
+// support for some of its fields ignore them
 type StorageClient interface {
-	Download(directory, path string) (fileName string, err error)
-	Upload(file, path string) error
+	Get(ctx context.Context, path string) (io.ReadCloser, error)
+	Put(ctx context.Context, reader io.Reader, path string, metadata *Metadata) error
+	Delete(ctx context.Context, path string) error
+}
+
+// Copier interface for storage clients able to copy files server-side
+// between paths of the same storage provider. If the metadata is nil, the
+// metadata and tags of the source file are kept
+type Copier interface {
+	Copy(ctx context.Context, srcPath, dstPath string, metadata *Metadata) error
+}
+
+// Metadata struct to represent the metadata and tags of a file
+type Metadata struct {
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	// User metadata with the keys normalized by config.MetadataKey
+	UserMetadata map[string]string
+	Tags         map[string]string
+}
+
+// ObjectInfo struct to represent the attributes of a stored file
+type ObjectInfo struct {
+	Size int64
+	ETag string
+	Metadata
+}
+
+// Stater interface for storage clients able to read the attributes of files
+// without downloading them
+type Stater interface {
+	Stat(ctx context.Context, path string) (*ObjectInfo, error)
+}
+
+// pinger interface for storage clients able to check the connectivity with
+// their provider. Any response other than a server error (e.g. access denied)
+// means that the provider is reachable
+type pinger interface {
+	ping(ctx context.Context) error
+}
+
+// GetClient factory function to get the appropiate storage client,
+// applying the connection settings and the retry policy of the provider
+func GetClient(provider *config.StorageProvider) (StorageClient, error) {
+	client, err := newClient(provider)
+	if err != nil {
+		return nil, err
+	}
+	return withRetry(client, provider.Retry), nil
+}
+
+// Ping checks the connectivity with a storage provider, without retries
+func Ping(ctx context.Context, provider *config.StorageProvider) error {
+	client, err := newClient(provider)
+	if err != nil {
+		return err
+	}
+	p, ok := client.(pinger)
+	if !ok {
+		return nil
+	}
+	return p.ping(ctx)
 }
 
-// GetClient factory function to get the appropiate storage client
-func GetClient(provider *config.StorageProvider) StorageClient {
+// newClient returns the storage client of a provider
+func newClient(provider *config.StorageProvider) (StorageClient, error) {
 	switch providerType := strings.ToLower(provider.Type); providerType {
+	case "s3":
+		return getS3Client(provider)
 	case "minio":
-		return getMinioClient(provider.Auth.Endpoint, provider.Auth.AccessKey, provider.Auth.SecretKey)
+		return getMinioClient(provider)
+	case "onedata":
+		return getOnedataClient(provider)
+	case "local":
+		return getLocalClient(provider)
 	default:
-		return nil
+		return nil, errInvalidProvider
 	}
 }
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// localClient struct to represent clients of directories mounted in the
+// function (e.g. NFS volumes)
+type localClient struct {
+	directory string
+}
+
+// Get method to open a reader to files stored in the directory
+func (lc *localClient) Get(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	localPath, err := lc.localPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	return file, nil
+}
+
+// Put method to write files to the directory, creating the missing folders.
+// Files are written to a temporary file first, so they are never left
+// incomplete. The metadata is ignored
+func (lc *localClient) Put(ctx context.Context, reader io.Reader, filePath string, metadata *Metadata) error {
+	localPath, err := lc.localPath(filePath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(localPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating folder: %w", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, "."+filepath.Base(localPath)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Error creating file: %w", err)
+	}
+	_, err = io.Copy(tmpFile, reader)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), localPath)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("Error writing file: %w", err)
+	}
+	return nil
+}
+
+// Delete method to remove files from the directory. Missing files are not
+// considered an error
+func (lc *localClient) Delete(ctx context.Context, filePath string) error {
+	localPath, err := lc.localPath(filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error deleting file: %w", err)
+	}
+	return nil
+}
+
+// Stat method to read the size of files stored in the directory. The content
+// type is guessed from the extension, and files have no metadata or tags
+func (lc *localClient) Stat(ctx context.Context, filePath string) (*ObjectInfo, error) {
+	localPath, err := lc.localPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file attributes: %w", err)
+	}
+	return &ObjectInfo{
+		Size: fileInfo.Size(),
+		Metadata: Metadata{
+			ContentType:  mime.TypeByExtension(filepath.Ext(localPath)),
+			UserMetadata: map[string]string{},
+			Tags:         map[string]string{},
+		},
+	}, nil
+}
+
+// ping method to check that the directory is still mounted
+func (lc *localClient) ping(ctx context.Context) error {
+	if _, err := os.Stat(lc.directory); err != nil {
+		return fmt.Errorf("Error opening directory: %w", err)
+	}
+	return nil
+}
+
+// localPath returns the path of a file inside the directory, so paths can't
+// reference files outside of it
+func (lc *localClient) localPath(filePath string) (string, error) {
+	cleanPath := path.Clean("/" + filePath)
+	if cleanPath == "/" {
+		return "", errInvalidPath
+	}
+	return filepath.Join(lc.directory, filepath.FromSlash(cleanPath)), nil
+}
+
+func getLocalClient(provider *config.StorageProvider) (StorageClient, error) {
+	info, err := os.Stat(provider.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("Error opening directory: '%s' is not a directory", provider.Directory)
+	}
+	return &localClient{directory: provider.Directory}, nil
+}
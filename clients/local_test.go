@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+func TestLocalClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multi-out-faas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client, err := getLocalClient(&config.StorageProvider{Directory: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Put(context.Background(), strings.NewReader("content"), "output/videos/out.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "output", "videos", "out.txt"))
+	if err != nil || string(content) != "content" {
+		t.Error("Error writing file to the local directory")
+	}
+	// The temporary files are removed
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, "output", "videos")); len(files) != 1 {
+		t.Error("Error removing temporary files")
+	}
+
+	// Paths can't reference files outside the directory
+	reader, err := client.Get(context.Background(), "/../output/videos/out.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = ioutil.ReadAll(reader)
+	reader.Close()
+	if string(content) != "content" {
+		t.Error("Error reading file from the local directory")
+	}
+
+	if _, err := client.Get(context.Background(), "missing.txt"); err == nil {
+		t.Error("Error reading missing file from the local directory")
+	}
+
+	info, err := client.(Stater).Stat(context.Background(), "output/videos/out.txt")
+	if err != nil || info.Size != 7 || info.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Error reading file attributes from the local directory: %+v, %v", info, err)
+	}
+
+	if err := client.Delete(context.Background(), "output/videos/out.txt"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output", "videos", "out.txt")); !os.IsNotExist(err) {
+		t.Error("Error deleting file from the local directory")
+	}
+	if err := client.Delete(context.Background(), "output/videos/out.txt"); err != nil {
+		t.Error("Error deleting missing file from the local directory")
+	}
+
+	if _, err := getLocalClient(&config.StorageProvider{Directory: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("Error opening missing local directory")
+	}
+}
@@ -20,89 +20,25 @@
 package clients
 
 import (
-	"errors"
-	"io"
-	"os"
-	"path/filepath"
-	"strings"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
 )
 
-// MinioClient struct to represent minio clients using aws-sdk-go/service/s3
+// MinioClient struct to represent minio clients using aws-sdk-go/service/s3.
+// MinIO is S3 compatible, so it reuses the methods of the S3 client
 type minioClient struct {
-	s3Client *s3.S3
-}
-
-// Download method to get files from minio
-func (mc *minioClient) Download(directory, path string) (fileName string, err error) {
-	fileName = filepath.Base(path)
-	pathSlice := strings.SplitN(strings.Trim(path, "/"), "/", 2)
-	bucket := pathSlice[0]
-	key := pathSlice[1]
-
-	file, err := os.Create(directory + "/" + fileName)
-	if err != nil {
-		return "", errors.New("Error creating file")
-	}
-	defer file.Close()
-
-	result, err := mc.s3Client.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
-	if err != nil {
-		return "", errors.New("Error downloading file: " + err.Error())
-	}
-	defer result.Body.Close()
-
-	_, err = io.Copy(file, result.Body)
-	if err != nil {
-		return "", errors.New("Error saving new file")
-	}
-
-	return file.Name(), nil
+	s3Client
 }
 
-// Upload method to push files to minio
-func (mc *minioClient) Upload(file, path string) error {
-	pathSlice := strings.SplitN(strings.Trim(path, "/"), "/", 2)
-	bucket := pathSlice[0]
-	key := pathSlice[1]
-
-	f, err := os.Open(file)
-	if err != nil {
-		return errors.New("Error opening file")
-	}
-	defer f.Close()
-
-	_, err = mc.s3Client.PutObject(&s3.PutObjectInput{
-		Body:   f,
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
+// getMinioClient returns a client for MinIO. By default, TLS is only used if
+// the endpoint scheme is "https" and buckets are addressed using the path style
+func getMinioClient(provider *config.StorageProvider) (StorageClient, error) {
+	s3config, err := newS3Config(provider, s3Defaults{useTLS: false, pathStyle: true})
 	if err != nil {
-		return errors.New("Error uploading file: " + err.Error())
+		return nil, err
 	}
 
-	return nil
-}
-
-func getMinioClient(endpoint, accessKey, secretKey string) StorageClient {
-	s3config := &aws.Config{
-		Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
-		Endpoint:         aws.String(endpoint),
-		Region:           aws.String("us-east-1"),
-		DisableSSL:       aws.Bool(true),
-		S3ForcePathStyle: aws.Bool(true),
-	}
-	newSession := session.New(s3config)
-	s3Client := s3.New(newSession)
-
 	return &minioClient{
-		s3Client: s3Client,
-	}
+		*newS3Client(s3config, &provider.Multipart),
+	}, nil
 }
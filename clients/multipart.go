@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// maxCopyObjectSize is the size of the largest file that can be copied with
+// a single CopyObject request (5 GiB). Larger files are copied in parts
+var maxCopyObjectSize int64 = 5 * 1024 * 1024 * 1024
+
+// copyPartSize is the minimum size of the parts copied server-side, which
+// don't go through the function, so they are larger than the uploaded ones
+var copyPartSize int64 = 512 * 1024 * 1024
+
+// Sizer interface for the readers that know the size of their content, so
+// the part size of the multipart uploads can be adjusted
+type Sizer interface {
+	Size() int64
+}
+
+// uploadPartSize returns the part size of an upload of size bytes (0 if
+// unknown), increasing the default one if the file would exceed the maximum
+// number of parts. Parts are rounded up to MiB
+func uploadPartSize(partSize, size int64) int64 {
+	const mib = 1024 * 1024
+	if min := (size + s3manager.MaxUploadParts - 1) / s3manager.MaxUploadParts; min > partSize {
+		partSize = (min + mib - 1) / mib * mib
+	}
+	return partSize
+}
+
+// multipartCopy copies a file of size bytes server-side with concurrent
+// UploadPartCopy requests, which aren't limited to 5 GiB like CopyObject.
+// The metadata and tags of the source file are kept if metadata is nil.
+// The upload is aborted if any part fails
+func (sc *s3Client) multipartCopy(ctx context.Context, srcPath, dstPath string, size int64, etag string, metadata *Metadata) error {
+	srcBucket, srcKey, err := splitS3Path(srcPath)
+	if err != nil {
+		return err
+	}
+	dstBucket, dstKey, err := splitS3Path(dstPath)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		info, err := sc.Stat(ctx, srcPath)
+		if err != nil {
+			return fmt.Errorf("Error copying file: %w", err)
+		}
+		metadata = &info.Metadata
+	}
+
+	upload, err := sc.s3Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:          aws.String(dstBucket),
+		Key:             aws.String(dstKey),
+		ContentType:     optionalString(metadata.ContentType),
+		ContentEncoding: optionalString(metadata.ContentEncoding),
+		CacheControl:    optionalString(metadata.CacheControl),
+		Metadata:        aws.StringMap(metadata.UserMetadata),
+		Tagging:         encodeTags(metadata.Tags),
+	})
+	if err != nil {
+		return fmt.Errorf("Error copying file: %w", err)
+	}
+
+	partSize := uploadPartSize(copyPartSize, size)
+	numParts := int((size + partSize - 1) / partSize)
+	parts := make([]*s3.CompletedPart, numParts)
+	copySource := &url.URL{Path: srcBucket + "/" + srcKey}
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, sc.concurrency)
+	for i := 0; i < numParts; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := int64(i) * partSize
+			end := start + partSize - 1
+			if end >= size {
+				end = size - 1
+			}
+			out, err := sc.s3Client.UploadPartCopyWithContext(partCtx, &s3.UploadPartCopyInput{
+				Bucket:            aws.String(dstBucket),
+				Key:               aws.String(dstKey),
+				UploadId:          upload.UploadId,
+				PartNumber:        aws.Int64(int64(i + 1)),
+				CopySource:        aws.String(copySource.EscapedPath()),
+				CopySourceRange:   aws.String("bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)),
+				CopySourceIfMatch: optionalString(etag),
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			part := &s3.CompletedPart{PartNumber: aws.Int64(int64(i + 1))}
+			if out.CopyPartResult != nil {
+				part.ETag = out.CopyPartResult.ETag
+			}
+			parts[i] = part
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		_, firstErr = sc.s3Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(dstBucket),
+			Key:             aws.String(dstKey),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if firstErr != nil {
+		sc.s3Client.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucket),
+			Key:      aws.String(dstKey),
+			UploadId: upload.UploadId,
+		})
+		return fmt.Errorf("Error copying file: %w", firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+func TestUploadPartSize(t *testing.T) {
+	const mib = 1024 * 1024
+	tests := []struct {
+		size     int64
+		expected int64
+	}{
+		{0, 5 * mib},
+		{1024 * mib, 5 * mib},
+		{s3manager.MaxUploadParts * 5 * mib, 5 * mib},
+		{s3manager.MaxUploadParts*5*mib + 1, 6 * mib},
+		{s3manager.MaxUploadParts * 100 * mib, 100 * mib},
+	}
+	for _, test := range tests {
+		if partSize := uploadPartSize(5*mib, test.size); partSize != test.expected {
+			t.Errorf("Error calculating the part size of %d bytes. Expected: %d. Received: %d", test.size, test.expected, partSize)
+		}
+	}
+}
+
+func TestS3MultipartCopy(t *testing.T) {
+	defer func(maxSize, partSize int64) {
+		maxCopyObjectSize, copyPartSize = maxSize, partSize
+	}(maxCopyObjectSize, copyPartSize)
+	maxCopyObjectSize, copyPartSize = 10, 6
+
+	content := "0123456789abcdefghij"
+	etag := `"etag"`
+	var mu sync.Mutex
+	var ranges []string
+	var parts map[string]string
+	var copyObject, aborted bool
+	objects := make(map[string]string)
+	headers := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		bucket := strings.SplitN(r.Host, ".", 2)[0]
+		object := bucket + r.URL.Path
+		query := r.URL.Query()
+		_, uploads := query["uploads"]
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Header().Set("ETag", `"etag"`)
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`<Tagging><TagSet><Tag><Key>Project</Key><Value>grycap</Value></Tag></TagSet></Tagging>`))
+		case r.Method == http.MethodPost && uploads:
+			headers[object] = r.Header
+			parts = make(map[string]string)
+			w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut && query.Get("uploadId") == "upload":
+			if r.Header.Get("X-Amz-Copy-Source-If-Match") != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`<Error><Code>PreconditionFailed</Code></Error>`))
+				return
+			}
+			byteRange := r.Header.Get("X-Amz-Copy-Source-Range")
+			ranges = append(ranges, byteRange)
+			var start, end int
+			fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end)
+			parts[query.Get("partNumber")] = content[start : end+1]
+			w.Write([]byte(`<CopyPartResult><ETag>part</ETag></CopyPartResult>`))
+		case r.Method == http.MethodPost && query.Get("uploadId") == "upload":
+			body, _ := ioutil.ReadAll(r.Body)
+			var assembled string
+			for i := 1; strings.Contains(string(body), fmt.Sprintf("<PartNumber>%d</PartNumber>", i)); i++ {
+				assembled += parts[fmt.Sprint(i)]
+			}
+			objects[object] = assembled
+			w.Write([]byte(`<CompleteMultipartUploadResult><ETag>etag</ETag></CompleteMultipartUploadResult>`))
+		case r.Method == http.MethodDelete && query.Get("uploadId") == "upload":
+			aborted = true
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			copyObject = true
+		}
+	}))
+	defer server.Close()
+	client := newTestS3Client(t, server, &config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL})
+	client.s3Client.Config.MaxRetries = aws.Int(0)
+
+	// Copies keeping the metadata and tags of the source file
+	if err := client.Copy(context.Background(), "in/video.mp4", "out/video.mp4", nil); err != nil {
+		t.Fatal(err)
+	}
+	if copyObject {
+		t.Error("Error copying large file in parts: CopyObject was used")
+	}
+	if objects["out/video.mp4"] != content {
+		t.Errorf("Error copying large file in parts. Expected: %s. Received: %s", content, objects["out/video.mp4"])
+	}
+	sort.Strings(ranges)
+	if expected := []string{"bytes=0-5", "bytes=12-17", "bytes=18-19", "bytes=6-11"}; fmt.Sprint(ranges) != fmt.Sprint(expected) {
+		t.Errorf("Error copying the parts of the file. Expected: %v. Received: %v", expected, ranges)
+	}
+	if h := headers["out/video.mp4"]; h.Get("Content-Type") != "video/mp4" || h.Get("X-Amz-Tagging") != "Project=grycap" {
+		t.Errorf("Error keeping file metadata when copying in parts: %v", h)
+	}
+
+	// Copies replacing the metadata
+	if err := client.Copy(context.Background(), "in/video.mp4", "out/tagged.mp4", &Metadata{Tags: map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if h := headers["out/tagged.mp4"]; h.Get("Content-Type") != "" || h.Get("X-Amz-Tagging") != "a=b" {
+		t.Errorf("Error replacing file metadata when copying in parts: %v", h)
+	}
+
+	// Copies over the source file, which store the checksums as metadata
+	checksums := &Metadata{UserMetadata: map[string]string{"sha256": "digest"}}
+	if err := client.Copy(context.Background(), "out/video.mp4", "out/video.mp4", checksums); err != nil {
+		t.Fatal(err)
+	}
+	if objects["out/video.mp4"] != content || headers["out/video.mp4"].Get("X-Amz-Meta-Sha256") != "digest" {
+		t.Errorf("Error replacing the metadata of large file in parts: %v", headers["out/video.mp4"])
+	}
+
+	// Source files modified after reading their ETag abort the upload
+	mu.Lock()
+	etag = `"modified"`
+	mu.Unlock()
+	if err := client.Copy(context.Background(), "in/video.mp4", "out/failed.mp4", &Metadata{}); err == nil {
+		t.Error("Error copying modified file in parts: no error returned")
+	}
+	if _, ok := objects["out/failed.mp4"]; ok || !aborted {
+		t.Error("Error aborting the copy of a modified file")
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+const (
+	cdmiPath    = "/cdmi"
+	cdmiVersion = "1.1.1"
+)
+
+// onedataClient struct to represent Onedata clients using the Oneprovider CDMI API
+type onedataClient struct {
+	endpoint   string
+	token      string
+	space      string
+	httpClient *http.Client
+}
+
+// Get method to open a reader to files stored in Onedata
+func (oc *onedataClient) Get(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	spacePath := oc.spacePath(filePath)
+	if spacePath == "" {
+		return nil, errInvalidPath
+	}
+
+	res, err := oc.doRequest(ctx, http.MethodGet, spacePath, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error downloading file: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Error downloading file: %w", &statusError{res.StatusCode})
+	}
+
+	return res.Body, nil
+}
+
+// Put method to push the content of a reader to Onedata, creating the missing
+// folders. Only the content type of the metadata is stored
+func (oc *onedataClient) Put(ctx context.Context, reader io.Reader, filePath string, metadata *Metadata) error {
+	spacePath := oc.spacePath(filePath)
+	if spacePath == "" {
+		return errInvalidPath
+	}
+
+	if err := oc.createFolders(ctx, path.Dir(spacePath)); err != nil {
+		return err
+	}
+
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if metadata != nil && metadata.ContentType != "" {
+		headers["Content-Type"] = metadata.ContentType
+	}
+	res, err := oc.doRequest(ctx, http.MethodPut, spacePath, reader, headers)
+	if err != nil {
+		return fmt.Errorf("Error uploading file: %w", err)
+	}
+	defer res.Body.Close()
+	if !isSuccessStatus(res.StatusCode) {
+		return fmt.Errorf("Error uploading file: %w", &statusError{res.StatusCode})
+	}
+
+	return nil
+}
+
+// Delete method to remove files from Onedata. Files that don't exist are ignored
+func (oc *onedataClient) Delete(ctx context.Context, filePath string) error {
+	spacePath := oc.spacePath(filePath)
+	if spacePath == "" {
+		return errInvalidPath
+	}
+
+	res, err := oc.doRequest(ctx, http.MethodDelete, spacePath, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error deleting file: %w", err)
+	}
+	defer res.Body.Close()
+	if !isSuccessStatus(res.StatusCode) && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Error deleting file: %w", &statusError{res.StatusCode})
+	}
+
+	return nil
+}
+
+// Stat method to read the size and content type of files stored in Onedata.
+// The CDMI metadata isn't read, so files have no metadata or tags
+func (oc *onedataClient) Stat(ctx context.Context, filePath string) (*ObjectInfo, error) {
+	spacePath := oc.spacePath(filePath)
+	if spacePath == "" {
+		return nil, errInvalidPath
+	}
+
+	res, err := oc.doRequest(ctx, http.MethodHead, spacePath, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file attributes: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error reading file attributes: %w", &statusError{res.StatusCode})
+	}
+
+	return &ObjectInfo{
+		Size: res.ContentLength,
+		Metadata: Metadata{
+			ContentType:  res.Header.Get("Content-Type"),
+			UserMetadata: map[string]string{},
+			Tags:         map[string]string{},
+		},
+	}, nil
+}
+
+// ping method to check the connectivity with the Oneprovider requesting the space
+func (oc *onedataClient) ping(ctx context.Context) error {
+	res, err := oc.doRequest(ctx, http.MethodHead, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= http.StatusInternalServerError {
+		return &statusError{res.StatusCode}
+	}
+	return nil
+}
+
+// createFolders creates all the folders of a space-relative path that don't exist yet
+func (oc *onedataClient) createFolders(ctx context.Context, folderPath string) error {
+	if folderPath == "." || folderPath == "/" {
+		return nil
+	}
+	var current string
+	for _, folder := range strings.Split(folderPath, "/") {
+		current = path.Join(current, folder)
+		res, err := oc.doRequest(ctx, http.MethodHead, current+"/", nil, nil)
+		if err != nil {
+			return fmt.Errorf("Error checking folder '%s': %w", current, err)
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			continue
+		}
+
+		headers := map[string]string{
+			"X-CDMI-Specification-Version": cdmiVersion,
+			"Content-Type":                 "application/cdmi-container",
+		}
+		res, err = oc.doRequest(ctx, http.MethodPut, current+"/", nil, headers)
+		if err != nil {
+			return fmt.Errorf("Error creating folder '%s': %w", current, err)
+		}
+		res.Body.Close()
+		if !isSuccessStatus(res.StatusCode) {
+			return fmt.Errorf("Error creating folder '%s': %w", current, &statusError{res.StatusCode})
+		}
+	}
+	return nil
+}
+
+// doRequest sends an authenticated request to the CDMI API of the Oneprovider.
+// The path must be relative to the space
+func (oc *onedataClient) doRequest(ctx context.Context, method, spacePath string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	u := &url.URL{Path: cdmiPath + "/" + oc.space + "/" + spacePath}
+	req, err := http.NewRequest(method, oc.endpoint+u.EscapedPath(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Auth-Token", oc.token)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return oc.httpClient.Do(req)
+}
+
+// spacePath returns the path relative to the space, removing the space name
+// if the path starts with it (as in OneTrigger events)
+func (oc *onedataClient) spacePath(filePath string) string {
+	filePath = strings.Trim(filePath, "/")
+	if strings.HasPrefix(filePath, oc.space+"/") {
+		filePath = strings.TrimPrefix(filePath, oc.space+"/")
+	}
+	return filePath
+}
+
+// statusError struct to represent unexpected status codes returned by the Oneprovider
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return "Oneprovider returned status " + strconv.Itoa(e.statusCode)
+}
+
+func isSuccessStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// getOnedataClient returns a client for the Oneprovider. TLS is used by
+// default unless the endpoint scheme is "http" or it is disabled in the settings
+func getOnedataClient(provider *config.StorageProvider) (StorageClient, error) {
+	httpClient, err := newHTTPClient(&provider.TLS)
+	if err != nil {
+		return nil, err
+	}
+	auth := &provider.Auth
+	secure := useTLS(auth.Endpoint, &provider.TLS, true)
+
+	return &onedataClient{
+		endpoint:   resolveEndpoint(auth.Endpoint, secure),
+		token:      auth.Token,
+		space:      strings.Trim(auth.Space, "/"),
+		httpClient: httpClient,
+	}, nil
+}
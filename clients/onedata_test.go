@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// newTestOneprovider returns a server emulating the Oneprovider CDMI API
+func newTestOneprovider(t *testing.T, files map[string]string, folders map[string]bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := strings.TrimPrefix(r.URL.Path, "/cdmi/")
+		switch {
+		case r.Method == http.MethodHead:
+			if !folders[p] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut && strings.HasSuffix(p, "/"):
+			if r.Header.Get("Content-Type") != "application/cdmi-container" {
+				t.Error("Invalid content type creating folder")
+			}
+			folders[p] = true
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			files[p] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet:
+			content, ok := files[p]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(content))
+		case r.Method == http.MethodDelete:
+			if _, ok := files[p]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(files, p)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestOnedataDownloadUpload(t *testing.T) {
+	files := map[string]string{"my-space/files/in.txt": "content"}
+	folders := map[string]bool{"my-space/files/": true}
+	server := newTestOneprovider(t, files, folders)
+	defer server.Close()
+
+	client, err := getOnedataClient(&config.StorageProvider{
+		Auth: config.Auth{
+			Endpoint: server.URL,
+			Token:    "token",
+			Space:    "my-space",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OneTrigger event paths start with the space name
+	reader, err := client.Get(context.Background(), "/my-space/files/in.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if err := client.Put(context.Background(), reader, "files/output/videos/out.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if files["my-space/files/output/videos/out.txt"] != "content" {
+		t.Error("Error uploading file to Onedata")
+	}
+	if !folders["my-space/files/output/"] || !folders["my-space/files/output/videos/"] {
+		t.Error("Error creating the missing folders in Onedata")
+	}
+
+	if _, err := client.Get(context.Background(), "files/missing.txt"); err == nil {
+		t.Error("Error downloading missing file from Onedata")
+	}
+
+	if err := client.Delete(context.Background(), "files/output/videos/out.txt"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := files["my-space/files/output/videos/out.txt"]; ok {
+		t.Error("Error deleting file from Onedata")
+	}
+	// Deleting a missing file is not an error
+	if err := client.Delete(context.Background(), "files/missing.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOnedataEndpoint(t *testing.T) {
+	client, _ := getOnedataClient(&config.StorageProvider{
+		Auth: config.Auth{Endpoint: "oneprovider.example/", Space: "/space/"},
+	})
+	if oc := client.(*onedataClient); oc.endpoint != "https://oneprovider.example" || oc.space != "space" {
+		t.Error("Error setting the Onedata endpoint")
+	}
+
+	disabled := false
+	client, _ = getOnedataClient(&config.StorageProvider{
+		Auth: config.Auth{Endpoint: "https://oneprovider.example"},
+		TLS:  config.TLS{Enabled: &disabled},
+	})
+	if oc := client.(*onedataClient); oc.endpoint != "http://oneprovider.example" {
+		t.Error("Error disabling TLS in the Onedata endpoint")
+	}
+}
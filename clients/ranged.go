@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// rangedPart struct to represent the content of a downloaded part
+type rangedPart struct {
+	data []byte
+	err  error
+}
+
+// rangedReader downloads the parts of a file with concurrent ranged requests,
+// returning them in order. The parts not read yet are kept in memory, up to
+// the concurrency of the client
+type rangedReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// current part, starting with the body of the first request
+	current  io.ReadCloser
+	buffered bool
+	next     int
+	parts    []chan rangedPart
+	// slots limits the parts downloaded or waiting to be read
+	slots chan struct{}
+}
+
+// newRangedReader starts downloading the rest of the parts of a file,
+// whose first part is being read from first. The ETag of the first part is
+// required in the next ones, so they can't belong to a modified file
+func newRangedReader(ctx context.Context, sc *s3Client, input *s3.GetObjectInput, etag string, first io.ReadCloser, size int64) *rangedReader {
+	ctx, cancel := context.WithCancel(ctx)
+	numParts := int((size + sc.partSize - 1) / sc.partSize)
+	rr := &rangedReader{
+		ctx:     ctx,
+		cancel:  cancel,
+		current: first,
+		next:    1,
+		parts:   make([]chan rangedPart, numParts),
+		slots:   make(chan struct{}, sc.concurrency),
+	}
+	for i := range rr.parts {
+		rr.parts[i] = make(chan rangedPart, 1)
+	}
+
+	go func() {
+		for i := 1; i < numParts; i++ {
+			select {
+			case rr.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			partInput := *input
+			start := int64(i) * sc.partSize
+			partInput.Range = aws.String(byteRange(start, sc.partSize))
+			if etag != "" {
+				partInput.IfMatch = aws.String(etag)
+			}
+			expected := size - start
+			if expected > sc.partSize {
+				expected = sc.partSize
+			}
+			go func(i int) {
+				rr.parts[i] <- downloadPart(ctx, sc, &partInput, expected)
+			}(i)
+		}
+	}()
+	return rr
+}
+
+// downloadPart reads the content of a part, checking its size
+func downloadPart(ctx context.Context, sc *s3Client, input *s3.GetObjectInput, expected int64) rangedPart {
+	result, err := sc.s3Client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return rangedPart{err: fmt.Errorf("Error downloading part %s: %w", aws.StringValue(input.Range), err)}
+	}
+	defer result.Body.Close()
+	data, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		return rangedPart{err: fmt.Errorf("Error downloading part %s: %w", aws.StringValue(input.Range), err)}
+	}
+	if int64(len(data)) != expected {
+		return rangedPart{err: fmt.Errorf("Error downloading part %s: got %d bytes", aws.StringValue(input.Range), len(data))}
+	}
+	return rangedPart{data: data}
+}
+
+// Read method to read the parts in order
+func (rr *rangedReader) Read(p []byte) (int, error) {
+	for {
+		if rr.current != nil {
+			n, err := rr.current.Read(p)
+			if err != io.EOF {
+				return n, err
+			}
+			rr.current.Close()
+			rr.current = nil
+			// Free the slot of the part, so the next one can be downloaded
+			if rr.buffered {
+				<-rr.slots
+			}
+			if n > 0 {
+				return n, nil
+			}
+		}
+		if rr.next == len(rr.parts) {
+			return 0, io.EOF
+		}
+		select {
+		case part := <-rr.parts[rr.next]:
+			if part.err != nil {
+				return 0, part.err
+			}
+			rr.current = ioutil.NopCloser(bytes.NewReader(part.data))
+			rr.buffered = true
+			rr.next++
+		case <-rr.ctx.Done():
+			return 0, rr.ctx.Err()
+		}
+	}
+}
+
+// Close method to stop the pending downloads
+func (rr *rangedReader) Close() error {
+	rr.cancel()
+	if rr.current != nil {
+		return rr.current.Close()
+	}
+	return nil
+}
+
+// byteRange returns the value of the Range header to request a part
+func byteRange(start, size int64) string {
+	return "bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(start+size-1, 10)
+}
+
+// rangeSize returns the size of the file from the Content-Range header of a
+// ranged response ("bytes 0-99/1234")
+func rangeSize(contentRange string) (int64, bool) {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
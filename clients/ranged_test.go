@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+func TestS3RangedGet(t *testing.T) {
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	etag := `"etag"`
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		currentETag := etag
+		mu.Unlock()
+		w.Header().Set("ETag", currentETag)
+		if strings.HasSuffix(r.URL.Path, "/empty.txt") {
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(""))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+	client := newTestS3Client(t, server, &config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL})
+	client.s3Client.Config.MaxRetries = aws.Int(0)
+	client.partSize = 10
+	client.concurrency = 2
+
+	reader, err := client.Get(context.Background(), "bucket/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("Error downloading file by ranges: %s, %v", data, err)
+	}
+	if len(ranges) != 4 || ranges[0] != "bytes=0-9" {
+		t.Errorf("Error requesting file ranges: %v", ranges)
+	}
+
+	// Empty files can't be requested by range
+	reader, err = client.Get(context.Background(), "bucket/empty.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(reader); len(data) != 0 {
+		t.Error("Error downloading empty file")
+	}
+	reader.Close()
+
+	// Files modified during the download fail
+	reader, err = client.Get(context.Background(), "bucket/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	etag = `"modified"`
+	mu.Unlock()
+	if _, err := ioutil.ReadAll(reader); err == nil {
+		t.Error("Error downloading modified file")
+	}
+	reader.Close()
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	var mu sync.Mutex
+	parts := make(map[string]int)
+	failPart := ""
+	completed, aborted := false, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && query.Get("uploadId") == "":
+			w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>file.bin</Key><UploadId>upload</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut && query.Get("partNumber") != "":
+			body, _ := ioutil.ReadAll(r.Body)
+			sum := md5.Sum(body)
+			if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if query.Get("partNumber") == failPart {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			parts[query.Get("partNumber")] = len(body)
+			w.Header().Set("ETag", `"part"`)
+		case r.Method == http.MethodPost:
+			completed = true
+			w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>file.bin</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`))
+		case r.Method == http.MethodDelete:
+			aborted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	s3config, err := newS3Config(&config.StorageProvider{Auth: config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL}}, s3Defaults{pathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3config.MaxRetries = aws.Int(0)
+	client := newS3Client(s3config, &config.Multipart{PartSize: 5, Concurrency: 2})
+
+	content := bytes.Repeat([]byte("a"), 12*1024*1024)
+	if err := client.Put(context.Background(), bytes.NewReader(content), "bucket/file.bin", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !completed || len(parts) != 3 || parts["1"] != 5*1024*1024 || parts["3"] != 2*1024*1024 {
+		t.Errorf("Error uploading file in parts: %v", parts)
+	}
+
+	// Failed uploads are aborted
+	failPart = "2"
+	if err := client.Put(context.Background(), bytes.NewReader(content), "bucket/file.bin", nil); err == nil {
+		t.Error("Error reporting failed part")
+	}
+	if !aborted {
+		t.Error("Error aborting failed multipart upload")
+	}
+}
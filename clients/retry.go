@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// Error classes used by the retry policies
+const (
+	ClassNetwork    = "network"
+	ClassTimeout    = "timeout"
+	ClassThrottling = "throttling"
+	ClassServer     = "server"
+)
+
+// Default values of the retry policies
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultMultiplier     = 2
+)
+
+// awsRequestErrorCode is the code of the SDK errors sending requests
+const awsRequestErrorCode = "RequestError"
+
+// throttlingCodes are the error codes returned by S3 when throttling requests
+var throttlingCodes = map[string]bool{
+	"Throttling":            true,
+	"ThrottlingException":   true,
+	"SlowDown":              true,
+	"RequestLimitExceeded":  true,
+	"TooManyRequests":       true,
+	"RequestThrottled":      true,
+	"ProvisionedThroughput": true,
+}
+
+// ErrorClass returns the class of an error returned by the storage clients
+// or an empty string if it doesn't belong to any retryable class
+func ErrorClass(err error) string {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+
+	var reqFailure awserr.RequestFailure
+	if errors.As(err, &reqFailure) {
+		if throttlingCodes[reqFailure.Code()] {
+			return ClassThrottling
+		}
+		return statusClass(reqFailure.StatusCode())
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if throttlingCodes[awsErr.Code()] {
+			return ClassThrottling
+		}
+		switch awsErr.Code() {
+		case request.ErrCodeResponseTimeout:
+			return ClassTimeout
+		case awsRequestErrorCode, request.ErrCodeRead:
+			// Network errors are wrapped by the SDK in the original error
+			if netErr, ok := awsErr.OrigErr().(net.Error); ok && netErr.Timeout() {
+				return ClassTimeout
+			}
+			return ClassNetwork
+		}
+		return ""
+	}
+
+	var sErr *statusError
+	if errors.As(err, &sErr) {
+		return statusClass(sErr.statusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ClassTimeout
+		}
+		return ClassNetwork
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ClassNetwork
+	}
+
+	return ""
+}
+
+func statusClass(statusCode int) string {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ClassThrottling
+	case statusCode == http.StatusRequestTimeout:
+		return ClassTimeout
+	case statusCode >= 500:
+		return ClassServer
+	}
+	return ""
+}
+
+// IsRetryable returns true if the error belongs to a class retried by the policy
+func IsRetryable(err error, policy *config.RetryPolicy) bool {
+	class := ErrorClass(err)
+	if class == "" {
+		return false
+	}
+	classes := policy.RetryOn
+	if len(classes) == 0 {
+		classes = config.RetryClasses
+	}
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the time to wait before the given retry (starting at 1)
+func Backoff(policy *config.RetryPolicy, retry int) time.Duration {
+	initial := defaultInitialBackoff
+	if policy.InitialBackoff > 0 {
+		initial = time.Duration(policy.InitialBackoff) * time.Millisecond
+	}
+	max := defaultMaxBackoff
+	if policy.MaxBackoff > 0 {
+		max = time.Duration(policy.MaxBackoff) * time.Millisecond
+	}
+	multiplier := float64(defaultMultiplier)
+	if policy.Multiplier > 0 {
+		multiplier = policy.Multiplier
+	}
+
+	backoff := float64(initial) * math.Pow(multiplier, float64(retry-1))
+	if backoff > float64(max) {
+		backoff = float64(max)
+	}
+	if policy.Jitter > 0 {
+		backoff *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// Retry calls fn until it succeeds, it returns an error not retried by the
+// policy or the attempts are exhausted, waiting between attempts with
+// exponential backoff. The attempt number (starting at 1) is passed to fn
+func Retry(ctx context.Context, policy *config.RetryPolicy, fn func(attempt int) error) error {
+	err := fn(1)
+	for attempt := 2; attempt <= policy.Attempts && err != nil && IsRetryable(err, policy); attempt++ {
+		timer := time.NewTimer(Backoff(policy, attempt-1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn(attempt)
+	}
+	return err
+}
+
+// retryClient wraps a storage client retrying the operations that can be
+// repeated safely. Uploads aren't retried here because the stream can't be
+// read again, they must be retried by reopening the source
+type retryClient struct {
+	StorageClient
+	policy config.RetryPolicy
+}
+
+// Get method to open a reader to a file, retrying on failure
+func (rc *retryClient) Get(ctx context.Context, path string) (reader io.ReadCloser, err error) {
+	err = Retry(ctx, &rc.policy, func(attempt int) error {
+		reader, err = rc.StorageClient.Get(ctx, path)
+		return err
+	})
+	return reader, err
+}
+
+// Delete method to remove a file, retrying on failure
+func (rc *retryClient) Delete(ctx context.Context, path string) error {
+	return Retry(ctx, &rc.policy, func(attempt int) error {
+		return rc.StorageClient.Delete(ctx, path)
+	})
+}
+
+// Stat method to read the attributes of a file, retrying on failure
+func (rc *retryClient) Stat(ctx context.Context, path string) (info *ObjectInfo, err error) {
+	stater, ok := rc.StorageClient.(Stater)
+	if !ok {
+		return nil, errStatNotSupported
+	}
+	err = Retry(ctx, &rc.policy, func(attempt int) error {
+		info, err = stater.Stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+// retryCopierClient wraps a storage client able to copy files server-side
+type retryCopierClient struct {
+	retryClient
+	copier Copier
+}
+
+// Copy method to copy files server-side, retrying on failure
+func (rc *retryCopierClient) Copy(ctx context.Context, srcPath, dstPath string, metadata *Metadata) error {
+	return Retry(ctx, &rc.policy, func(attempt int) error {
+		return rc.copier.Copy(ctx, srcPath, dstPath, metadata)
+	})
+}
+
+// withRetry wraps the client to apply the retry policy
+func withRetry(client StorageClient, policy config.RetryPolicy) StorageClient {
+	if client == nil || policy.Attempts <= 1 {
+		return client
+	}
+	rc := retryClient{
+		StorageClient: client,
+		policy:        policy,
+	}
+	if copier, ok := client.(Copier); ok {
+		return &retryCopierClient{
+			retryClient: rc,
+			copier:      copier,
+		}
+	}
+	return &rc
+}
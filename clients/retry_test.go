@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{errors.New("Unknown error"), ""},
+		{fmt.Errorf("Error downloading file: %w", context.Canceled), ""},
+		{fmt.Errorf("Error downloading file: %w", awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "id")), ClassThrottling},
+		{fmt.Errorf("Error downloading file: %w", awserr.NewRequestFailure(awserr.New("InternalError", "error", nil), 500, "id")), ClassServer},
+		{fmt.Errorf("Error downloading file: %w", awserr.NewRequestFailure(awserr.New("NoSuchKey", "not found", nil), 404, "id")), ""},
+		{fmt.Errorf("Error uploading file: %w", awserr.New("RequestError", "send request failed", &net.OpError{Op: "dial", Err: errors.New("refused")})), ClassNetwork},
+		{fmt.Errorf("Error uploading file: %w", awserr.New("RequestError", "send request failed", timeoutError{})), ClassTimeout},
+		{fmt.Errorf("Error uploading file: %w", &statusError{429}), ClassThrottling},
+		{fmt.Errorf("Error uploading file: %w", &statusError{502}), ClassServer},
+		{fmt.Errorf("Error uploading file: %w", &statusError{403}), ""},
+		{fmt.Errorf("Error uploading file: %w", &net.OpError{Op: "read", Err: timeoutError{}}), ClassTimeout},
+		{fmt.Errorf("Error uploading file: %w", io.ErrUnexpectedEOF), ClassNetwork},
+	}
+
+	for i, test := range tests {
+		if class := ErrorClass(test.err); class != test.class {
+			t.Errorf("Test %d: got class '%s', expected '%s'", i, class, test.class)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &config.RetryPolicy{InitialBackoff: 100, MaxBackoff: 1000, Multiplier: 3}
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	for i, backoff := range expected {
+		if b := Backoff(policy, i+1); b != backoff {
+			t.Errorf("Retry %d: got backoff %v, expected %v", i+1, b, backoff)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if b := Backoff(policy, 1); b < 50*time.Millisecond || b > 150*time.Millisecond {
+			t.Fatalf("Backoff %v out of the jitter range", b)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	serverErr := &statusError{500}
+	policy := &config.RetryPolicy{Attempts: 3, InitialBackoff: 1}
+
+	// Retried until the attempts are exhausted
+	calls := 0
+	err := Retry(context.Background(), policy, func(attempt int) error {
+		calls++
+		if attempt != calls {
+			t.Error("Invalid attempt number")
+		}
+		return serverErr
+	})
+	if err != serverErr || calls != 3 {
+		t.Errorf("Error retrying: %d calls", calls)
+	}
+
+	// Stops when it succeeds
+	calls = 0
+	err = Retry(context.Background(), policy, func(attempt int) error {
+		calls++
+		if attempt == 2 {
+			return nil
+		}
+		return serverErr
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Error retrying until success: %d calls", calls)
+	}
+
+	// Not retryable errors and classes not included in the policy
+	calls = 0
+	Retry(context.Background(), policy, func(attempt int) error {
+		calls++
+		return errors.New("Not retryable")
+	})
+	onlyThrottling := &config.RetryPolicy{Attempts: 3, InitialBackoff: 1, RetryOn: []string{ClassThrottling}}
+	Retry(context.Background(), onlyThrottling, func(attempt int) error {
+		calls++
+		return serverErr
+	})
+	if calls != 2 {
+		t.Errorf("Error retrying not retryable errors: %d calls", calls)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	client, _ := getS3Client(&config.StorageProvider{})
+	if withRetry(client, config.RetryPolicy{}) != client {
+		t.Error("Error applying an empty retry policy")
+	}
+	retried := withRetry(client, config.RetryPolicy{Attempts: 3})
+	if _, ok := retried.(Copier); !ok {
+		t.Error("Error keeping the copy method of the client")
+	}
+	onedata, _ := getOnedataClient(&config.StorageProvider{})
+	if _, ok := withRetry(onedata, config.RetryPolicy{Attempts: 3}).(Copier); ok {
+		t.Error("Error adding a copy method to the client")
+	}
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+const defaultS3Region = "us-east-1"
+
+var errInvalidPath = errors.New("Invalid path, it must contain the bucket name and the object key")
+
+// s3Client struct to represent Amazon S3 clients using aws-sdk-go/service/s3
+type s3Client struct {
+	s3Client *s3.S3
+	uploader *s3manager.Uploader
+	// size in bytes and number of concurrent parts of the ranged downloads
+	partSize    int64
+	concurrency int
+}
+
+// Get method to open a reader to files stored in S3. Files larger than the
+// part size are downloaded with concurrent ranged requests
+func (sc *s3Client) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if sc.concurrency <= 1 {
+		result, err := sc.s3Client.GetObjectWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("Error downloading file: %w", err)
+		}
+		return result.Body, nil
+	}
+
+	// The first part returns the size of the file
+	input.Range = aws.String(byteRange(0, sc.partSize))
+	result, err := sc.s3Client.GetObjectWithContext(ctx, input)
+	var reqFailure awserr.RequestFailure
+	if errors.As(err, &reqFailure) && reqFailure.StatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		// Empty files can't be requested by range
+		input.Range = nil
+		result, err = sc.s3Client.GetObjectWithContext(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error downloading file: %w", err)
+	}
+
+	size, ok := rangeSize(aws.StringValue(result.ContentRange))
+	if !ok || size <= sc.partSize {
+		return result.Body, nil
+	}
+	return newRangedReader(ctx, sc, input, aws.StringValue(result.ETag), result.Body, size), nil
+}
+
+// Put method to push the content of a reader to S3. The reader is uploaded
+// in parts, so the size of the file doesn't need to be known in advance,
+// although readers implementing Sizer allow uploading files larger than the
+// maximum number of parts of the configured size
+func (sc *s3Client) Put(ctx context.Context, reader io.Reader, path string, metadata *Metadata) error {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+
+	input := &s3manager.UploadInput{
+		Body:   reader,
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if metadata != nil {
+		input.ContentType = optionalString(metadata.ContentType)
+		input.ContentEncoding = optionalString(metadata.ContentEncoding)
+		input.CacheControl = optionalString(metadata.CacheControl)
+		input.Metadata = aws.StringMap(metadata.UserMetadata)
+		input.Tagging = encodeTags(metadata.Tags)
+	}
+	// Increase the part size if the file would exceed the maximum number of parts
+	var options []func(*s3manager.Uploader)
+	if sizer, ok := reader.(Sizer); ok && sizer.Size() > 0 {
+		partSize := uploadPartSize(sc.partSize, sizer.Size())
+		options = append(options, func(u *s3manager.Uploader) {
+			u.PartSize = partSize
+		})
+	}
+	_, err = sc.uploader.UploadWithContext(ctx, input, options...)
+	if err != nil {
+		return fmt.Errorf("Error uploading file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete method to remove files from S3
+func (sc *s3Client) Delete(ctx context.Context, path string) error {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = sc.s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting file: %w", err)
+	}
+
+	return nil
+}
+
+// Stat method to read the size, content type, eTag, user metadata and tags
+// of files stored in S3. Providers not supporting tags (e.g. older MinIO
+// releases) return no tags
+func (sc *s3Client) Stat(ctx context.Context, path string) (*ObjectInfo, error) {
+	bucket, key, err := splitS3Path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := sc.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file attributes: %w", err)
+	}
+	info := &ObjectInfo{
+		Size: aws.Int64Value(head.ContentLength),
+		ETag: strings.Trim(aws.StringValue(head.ETag), `"`),
+		Metadata: Metadata{
+			ContentType:     aws.StringValue(head.ContentType),
+			ContentEncoding: aws.StringValue(head.ContentEncoding),
+			CacheControl:    aws.StringValue(head.CacheControl),
+			UserMetadata:    make(map[string]string),
+			Tags:            make(map[string]string),
+		},
+	}
+	for k, v := range head.Metadata {
+		info.UserMetadata[config.MetadataKey(k)] = aws.StringValue(v)
+	}
+
+	tagging, err := sc.s3Client.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == "NotImplemented" {
+		return info, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file tags: %w", err)
+	}
+	for _, tag := range tagging.TagSet {
+		info.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return info, nil
+}
+
+// ping method to check the connectivity with S3 listing the buckets
+func (sc *s3Client) ping(ctx context.Context) error {
+	_, err := sc.s3Client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	var reqFailure awserr.RequestFailure
+	if errors.As(err, &reqFailure) && reqFailure.StatusCode() < http.StatusInternalServerError {
+		return nil
+	}
+	return err
+}
+
+// Copy method to copy files server-side between buckets of the same S3
+// provider, replacing the metadata and tags of the source file if set.
+// Files larger than 5 GiB are copied in parts (see multipartCopy)
+func (sc *s3Client) Copy(ctx context.Context, srcPath, dstPath string, metadata *Metadata) error {
+	srcBucket, srcKey, err := splitS3Path(srcPath)
+	if err != nil {
+		return err
+	}
+	dstBucket, dstKey, err := splitS3Path(dstPath)
+	if err != nil {
+		return err
+	}
+
+	// Files larger than 5 GiB can only be copied in parts
+	head, err := sc.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return fmt.Errorf("Error copying file: %w", err)
+	}
+	if size := aws.Int64Value(head.ContentLength); size > maxCopyObjectSize {
+		return sc.multipartCopy(ctx, srcPath, dstPath, size, aws.StringValue(head.ETag), metadata)
+	}
+
+	copySource := &url.URL{Path: srcBucket + "/" + srcKey}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource.EscapedPath()),
+	}
+	if metadata != nil {
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+		input.ContentType = optionalString(metadata.ContentType)
+		input.ContentEncoding = optionalString(metadata.ContentEncoding)
+		input.CacheControl = optionalString(metadata.CacheControl)
+		input.Metadata = aws.StringMap(metadata.UserMetadata)
+		input.Tagging = encodeTags(metadata.Tags)
+	}
+	_, err = sc.s3Client.CopyObjectWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("Error copying file: %w", err)
+	}
+
+	return nil
+}
+
+// optionalString returns nil for empty strings, so they aren't sent
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// encodeTags returns the tags encoded as URL query parameters, as expected
+// by the tagging header (nil if there are no tags)
+func encodeTags(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return aws.String(values.Encode())
+}
+
+// splitS3Path returns the bucket and the object key of a "bucket/key" path
+func splitS3Path(path string) (bucket, key string, err error) {
+	pathSlice := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(pathSlice) != 2 || pathSlice[0] == "" || pathSlice[1] == "" {
+		return "", "", errInvalidPath
+	}
+	return pathSlice[0], pathSlice[1], nil
+}
+
+// s3Defaults struct with the default connection settings of an S3 compatible provider
+type s3Defaults struct {
+	useTLS    bool
+	pathStyle bool
+}
+
+// newS3Config returns the aws config for an S3 compatible provider. For Amazon
+// S3 the endpoint is resolved from the region unless a custom one is provided.
+// The TLS and addressing style settings of the provider override the defaults.
+// If no access keys are provided the default credential chain is used
+// (environment variables, shared credentials file, IAM roles...)
+func newS3Config(provider *config.StorageProvider, defaults s3Defaults) (*aws.Config, error) {
+	auth := &provider.Auth
+	httpClient, err := newHTTPClient(&provider.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	secure := useTLS(auth.Endpoint, &provider.TLS, defaults.useTLS)
+	pathStyle := defaults.pathStyle
+	switch provider.Addressing {
+	case "path":
+		pathStyle = true
+	case "virtual":
+		pathStyle = false
+	}
+
+	s3config := &aws.Config{
+		Region:           aws.String(defaultS3Region),
+		DisableSSL:       aws.Bool(!secure),
+		S3ForcePathStyle: aws.Bool(pathStyle),
+		HTTPClient:       httpClient,
+	}
+	if auth.Region != "" {
+		s3config.Region = aws.String(auth.Region)
+	}
+	if auth.Endpoint != "" {
+		s3config.Endpoint = aws.String(resolveEndpoint(auth.Endpoint, secure))
+	}
+	if auth.AccessKey != "" || auth.SecretKey != "" {
+		s3config.Credentials = credentials.NewStaticCredentials(auth.AccessKey, auth.SecretKey, "")
+	}
+	return s3config, nil
+}
+
+// newS3Client returns a client for the S3 compatible provider defined in
+// s3config, with the multipart settings of the provider. The multipart
+// uploads are aborted if any part fails
+func newS3Client(s3config *aws.Config, multipart *config.Multipart) *s3Client {
+	svc := s3.New(session.New(s3config))
+
+	partSize := s3manager.DefaultUploadPartSize
+	if multipart.PartSize > 0 {
+		partSize = multipart.PartSize * 1024 * 1024
+	}
+	concurrency := s3manager.DefaultUploadConcurrency
+	if multipart.Concurrency > 0 {
+		concurrency = multipart.Concurrency
+	}
+
+	return &s3Client{
+		s3Client: svc,
+		uploader: s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+			u.PartSize = partSize
+			u.Concurrency = concurrency
+			u.LeavePartsOnError = false
+		}),
+		partSize:    partSize,
+		concurrency: concurrency,
+	}
+}
+
+func getS3Client(provider *config.StorageProvider) (StorageClient, error) {
+	s3config, err := newS3Config(provider, s3Defaults{useTLS: true, pathStyle: false})
+	if err != nil {
+		return nil, err
+	}
+	return newS3Client(s3config, &provider.Multipart), nil
+}
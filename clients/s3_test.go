@@ -0,0 +1,335 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// newTestS3Client returns an S3 client whose requests are always sent to the
+// test server, whatever the host of the request is
+func newTestS3Client(t *testing.T, server *httptest.Server, auth *config.Auth) *s3Client {
+	s3config, err := newS3Config(&config.StorageProvider{Auth: *auth}, s3Defaults{useTLS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3config.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+		},
+	}
+	return newS3Client(s3config, &config.Multipart{})
+}
+
+func TestS3Config(t *testing.T) {
+	c, err := newS3Config(&config.StorageProvider{}, s3Defaults{useTLS: true})
+	if err != nil || *c.Region != defaultS3Region || *c.DisableSSL || *c.S3ForcePathStyle || c.Endpoint != nil || c.Credentials != nil {
+		t.Error("Error setting the default S3 config")
+	}
+
+	c, err = newS3Config(&config.StorageProvider{
+		Auth: config.Auth{
+			AccessKey: "key",
+			SecretKey: "secret",
+			Region:    "eu-west-1",
+			Endpoint:  "http://localhost:9000",
+		},
+		Addressing: "path",
+	}, s3Defaults{useTLS: true})
+	if err != nil || *c.Region != "eu-west-1" || *c.Endpoint != "http://localhost:9000" || !*c.DisableSSL || !*c.S3ForcePathStyle || c.Credentials == nil {
+		t.Error("Error setting the S3 config")
+	}
+}
+
+func TestMinioConfig(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		provider  config.StorageProvider
+		endpoint  string
+		ssl       bool
+		pathStyle bool
+	}{
+		{
+			provider:  config.StorageProvider{Auth: config.Auth{Endpoint: "minio:9000"}},
+			endpoint:  "http://minio:9000",
+			pathStyle: true,
+		},
+		{
+			provider:  config.StorageProvider{Auth: config.Auth{Endpoint: "https://minio.example"}},
+			endpoint:  "https://minio.example",
+			ssl:       true,
+			pathStyle: true,
+		},
+		{
+			provider:  config.StorageProvider{Auth: config.Auth{Endpoint: "minio:9000"}, TLS: config.TLS{Enabled: &enabled}, Addressing: "virtual"},
+			endpoint:  "https://minio:9000",
+			ssl:       true,
+			pathStyle: false,
+		},
+	}
+
+	for i, test := range tests {
+		c, err := newS3Config(&test.provider, s3Defaults{useTLS: false, pathStyle: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *c.Endpoint != test.endpoint || *c.DisableSSL == test.ssl || *c.S3ForcePathStyle != test.pathStyle {
+			t.Errorf("Test %d: error setting the MinIO config", i)
+		}
+	}
+}
+
+func TestS3GetPutCopy(t *testing.T) {
+	objects := make(map[string]string)
+	headers := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Virtual-hosted-style requests: "<bucket>.<endpoint host>/<key>"
+		bucket := strings.SplitN(r.Host, ".", 2)[0]
+		object := bucket + r.URL.Path
+		switch r.Method {
+		case http.MethodPut:
+			headers[object] = r.Header
+			if copySource := r.Header.Get("X-Amz-Copy-Source"); copySource != "" {
+				src, _ := url.PathUnescape(copySource)
+				objects[object] = objects[src]
+				w.Write([]byte("<CopyObjectResult><ETag>etag</ETag></CopyObjectResult>"))
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[object] = string(body)
+		case http.MethodGet:
+			content, ok := objects[object]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(content))
+		case http.MethodDelete:
+			delete(objects, object)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestS3Client(t, server, &config.Auth{
+		AccessKey: "key",
+		SecretKey: "secret",
+		Endpoint:  server.URL,
+	})
+
+	metadata := &Metadata{
+		ContentType:  "text/plain",
+		CacheControl: "no-cache",
+		UserMetadata: map[string]string{"quality": "raw"},
+		Tags:         map[string]string{"routed-by": "multi-out-faas", "source-key": "in/file.txt"},
+	}
+	if err := client.Put(context.Background(), strings.NewReader("content"), "my-bucket/folder/output.txt", metadata); err != nil {
+		t.Error(err)
+	}
+	if objects["my-bucket/folder/output.txt"] != "content" {
+		t.Error("Error uploading file to S3")
+	}
+	h := headers["my-bucket/folder/output.txt"]
+	if h.Get("Content-Type") != "text/plain" || h.Get("Cache-Control") != "no-cache" || h.Get("X-Amz-Meta-Quality") != "raw" ||
+		h.Get("X-Amz-Tagging") != "routed-by=multi-out-faas&source-key=in%2Ffile.txt" || h.Get("Content-Encoding") != "" {
+		t.Errorf("Error uploading file metadata to S3: %v", h)
+	}
+
+	reader, err := client.Get(context.Background(), "my-bucket/folder/output.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	if string(content) != "content" {
+		t.Error("Error downloading file from S3")
+	}
+
+	if _, err := client.Get(context.Background(), "my-bucket/missing.txt"); err == nil {
+		t.Error("Error downloading missing file from S3")
+	}
+
+	if err := client.Copy(context.Background(), "my-bucket/folder/output.txt", "other-bucket/copy of output.txt", nil); err != nil {
+		t.Error(err)
+	}
+	if objects["other-bucket/copy of output.txt"] != "content" {
+		t.Error("Error copying file in S3")
+	}
+	if h := headers["other-bucket/copy of output.txt"]; h.Get("X-Amz-Metadata-Directive") != "" || h.Get("X-Amz-Tagging-Directive") != "" {
+		t.Errorf("Error keeping file metadata when copying in S3: %v", h)
+	}
+
+	// Copies replacing the metadata
+	if err := client.Copy(context.Background(), "my-bucket/folder/output.txt", "other-bucket/copy.txt", &Metadata{Tags: map[string]string{"a": "b"}}); err != nil {
+		t.Error(err)
+	}
+	if h := headers["other-bucket/copy.txt"]; h.Get("X-Amz-Metadata-Directive") != "REPLACE" || h.Get("X-Amz-Tagging-Directive") != "REPLACE" || h.Get("X-Amz-Tagging") != "a=b" {
+		t.Errorf("Error replacing file metadata when copying in S3: %v", h)
+	}
+
+	if err := client.Delete(context.Background(), "other-bucket/copy of output.txt"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := objects["other-bucket/copy of output.txt"]; ok {
+		t.Error("Error deleting file from S3")
+	}
+}
+
+func TestS3PutContentMD5(t *testing.T) {
+	var mu sync.Mutex
+	var requests, invalid int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := md5.Sum(body)
+		requests++
+		if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			invalid++
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	client := newTestS3Client(t, server, &config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL})
+	client.s3Client.Config.MaxRetries = aws.Int(0)
+
+	// The streamed files can't be read twice, but the uploader buffers the
+	// parts, so their Content-MD5 header is always sent
+	for _, size := range []int{7, 6 * 1024 * 1024} {
+		reader := io.MultiReader(strings.NewReader(strings.Repeat("a", size)))
+		if err := client.Put(context.Background(), reader, "bucket/file.bin", nil); err != nil {
+			t.Errorf("Error uploading file of %d bytes with Content-MD5: %v", size, err)
+		}
+	}
+	if requests != 3 || invalid != 0 {
+		t.Errorf("Error sending the Content-MD5 header: %d of %d uploads without it", invalid, requests)
+	}
+}
+
+func TestSplitS3Path(t *testing.T) {
+	bucket, key, err := splitS3Path("/bucket/folder/file.txt")
+	if err != nil || bucket != "bucket" || key != "folder/file.txt" {
+		t.Error("Error splitting S3 path")
+	}
+
+	for _, path := range []string{"", "bucket", "/bucket/"} {
+		if _, _, err := splitS3Path(path); err == nil {
+			t.Errorf("Error splitting invalid S3 path '%s'", path)
+		}
+	}
+}
+
+func TestS3Ping(t *testing.T) {
+	statusCode := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+	auth := &config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL}
+
+	// Access denied responses mean that the provider is reachable
+	if err := newTestS3Client(t, server, auth).ping(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	statusCode = http.StatusServiceUnavailable
+	client := newTestS3Client(t, server, auth)
+	client.s3Client.Config.MaxRetries = aws.Int(0)
+	if err := client.ping(context.Background()); err == nil {
+		t.Error("Error reporting unavailable provider")
+	}
+}
+
+func TestS3Stat(t *testing.T) {
+	taggingStatus := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/images/photo.jpg") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, ok := r.URL.Query()["tagging"]; ok {
+			w.WriteHeader(taggingStatus)
+			if taggingStatus == http.StatusOK {
+				w.Write([]byte(`<Tagging><TagSet><Tag><Key>Project</Key><Value>grycap</Value></Tag></TagSet></Tagging>`))
+			} else {
+				w.Write([]byte(`<Error><Code>NotImplemented</Code><Message>Not implemented</Message></Error>`))
+			}
+			return
+		}
+		w.Header().Set("Content-Length", "2048")
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"dd20b7e4b74467ff16ce2d901c054419"`)
+		w.Header().Set("X-Amz-Meta-Quality", "raw")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	auth := &config.Auth{AccessKey: "key", SecretKey: "secret", Endpoint: server.URL}
+	client := newTestS3Client(t, server, auth)
+	client.s3Client.Config.MaxRetries = aws.Int(0)
+
+	expected := &ObjectInfo{
+		Size: 2048,
+		ETag: "dd20b7e4b74467ff16ce2d901c054419",
+		Metadata: Metadata{
+			ContentType:  "image/jpeg",
+			CacheControl: "no-cache",
+			UserMetadata: map[string]string{"quality": "raw"},
+			Tags:         map[string]string{"Project": "grycap"},
+		},
+	}
+	if info, err := client.Stat(context.Background(), "bucket/images/photo.jpg"); err != nil || !reflect.DeepEqual(info, expected) {
+		t.Errorf("Error reading file attributes. Expected: %+v. Received: %+v, %v", expected, info, err)
+	}
+
+	// Providers not supporting tags
+	taggingStatus = http.StatusNotImplemented
+	expected.Tags = map[string]string{}
+	if info, err := client.Stat(context.Background(), "bucket/images/photo.jpg"); err != nil || !reflect.DeepEqual(info, expected) {
+		t.Errorf("Error reading file attributes without tags. Expected: %+v. Received: %+v, %v", expected, info, err)
+	}
+
+	if _, err := client.Stat(context.Background(), "bucket/missing.jpg"); err == nil {
+		t.Error("Error reading attributes of missing file")
+	}
+}
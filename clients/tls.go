@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// newHTTPClient returns the HTTP client used to connect to a storage provider
+// with the TLS settings applied
+func newHTTPClient(tlsSettings *config.TLS) (*http.Client, error) {
+	if tlsSettings.CABundle == "" && tlsSettings.ClientCert == "" && !tlsSettings.InsecureSkipVerify {
+		return &http.Client{}, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: tlsSettings.InsecureSkipVerify,
+	}
+
+	if tlsSettings.CABundle != "" {
+		caBundle, err := readPEM(tlsSettings.CABundle)
+		if err != nil {
+			return nil, errors.New("Error reading CA bundle: " + err.Error())
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, errors.New("Error reading CA bundle: no valid certificates found")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if tlsSettings.ClientCert != "" {
+		cert, err := readPEM(tlsSettings.ClientCert)
+		if err != nil {
+			return nil, errors.New("Error reading client certificate: " + err.Error())
+		}
+		key, err := readPEM(tlsSettings.ClientKey)
+		if err != nil {
+			return nil, errors.New("Error reading client key: " + err.Error())
+		}
+		keyPair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.New("Error loading client certificate: " + err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{keyPair}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// readPEM returns the PEM content of a setting, reading it from a file
+// if the value is not PEM encoded
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
+
+// useTLS returns whether to connect to the endpoint using TLS. The TLS
+// settings take precedence over the endpoint scheme, and the default value
+// is used if none of them set it
+func useTLS(endpoint string, tlsSettings *config.TLS, defaultTLS bool) bool {
+	if tlsSettings.Enabled != nil {
+		return *tlsSettings.Enabled
+	}
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		return strings.ToLower(endpoint[:i]) == "https"
+	}
+	return defaultTLS
+}
+
+// resolveEndpoint returns the endpoint with the scheme for the secure setting
+func resolveEndpoint(endpoint string, secure bool) string {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+	if endpoint == "" {
+		return ""
+	}
+	if secure {
+		return "https://" + endpoint
+	}
+	return "http://" + endpoint
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clients
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+func TestMinioTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Path-style requests: "/<bucket>/<key>"
+		if r.URL.Path != "/bucket/file.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, []byte(caBundle), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tls     config.TLS
+		success bool
+	}{
+		{tls: config.TLS{}, success: false},
+		{tls: config.TLS{CABundle: caBundle}, success: true},
+		{tls: config.TLS{CABundle: caFile}, success: true},
+		{tls: config.TLS{InsecureSkipVerify: true}, success: true},
+	}
+
+	for i, test := range tests {
+		client, err := getMinioClient(&config.StorageProvider{
+			Auth: config.Auth{
+				AccessKey: "key",
+				SecretKey: "secret",
+				Endpoint:  server.URL,
+			},
+			TLS: test.tls,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := client.Get(context.Background(), "bucket/file.txt")
+		if (err == nil) != test.success {
+			t.Errorf("Test %d: unexpected result connecting with TLS: %v", i, err)
+		}
+		if err == nil {
+			content, _ := ioutil.ReadAll(reader)
+			reader.Close()
+			if string(content) != "content" {
+				t.Errorf("Test %d: error downloading file using TLS", i)
+			}
+		}
+	}
+}
+
+func TestInvalidTLSSettings(t *testing.T) {
+	tests := []config.TLS{
+		{CABundle: "/missing/ca.pem"},
+		{CABundle: "-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----"},
+		{ClientCert: "/missing/cert.pem", ClientKey: "/missing/key.pem"},
+	}
+	for i, test := range tests {
+		if _, err := newHTTPClient(&test); err == nil {
+			t.Errorf("Test %d: error reporting invalid TLS settings", i)
+		}
+	}
+
+	if _, err := GetClient(&config.StorageProvider{Type: "minio", TLS: tests[0]}); err == nil || !strings.Contains(err.Error(), "CA bundle") {
+		t.Error("Error reporting invalid TLS settings when creating clients")
+	}
+}
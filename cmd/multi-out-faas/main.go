@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command multi-out-faas provides tools to work with the function outside
+// OpenFaaS, e.g. validating configuration files in CI pipelines or serving
+// the function as a standalone HTTP server:
+//
+//	multi-out-faas validate <CONFIG_FILE>
+//	multi-out-faas dry-run <CONFIG_FILE> <EVENT_FILE>
+//	multi-out-faas serve [-addr :8080] [-shutdown-timeout 30s] <CONFIG_FILE>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	//function "github.com/grycap/multi-out-faas"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/logging"
+	"handler/function"
+	"handler/function/config"
+	"handler/function/logging"
+)
+
+const usage = `Usage: multi-out-faas <command> [arguments]
+
+Commands:
+  validate <CONFIG_FILE>  check the configuration file, reporting every problem found
+  dry-run <CONFIG_FILE> <EVENT_FILE>
+                          print the destinations where the files of the event would
+                          be written, without transferring them ("-" reads the
+                          event from the standard input)
+  serve [-addr :8080] [-shutdown-timeout 30s] <CONFIG_FILE>
+                          route the events received over HTTP, exposing the
+                          /healthz and /readyz endpoints
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "validate":
+		os.Exit(validate(os.Args[2:]))
+	case "dry-run":
+		os.Exit(dryRun(os.Args[2:]))
+	case "serve":
+		os.Exit(serve(os.Args[2:]))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// validate reads the config file and prints its problems, returning the exit code
+func validate(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	configFile, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening config file: "+err.Error())
+		return 1
+	}
+	defer configFile.Close()
+
+	if _, err := config.ReadConfig(configFile); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Println("Config file '" + args[0] + "' is valid")
+	return 0
+}
+
+// dryRun prints the routing decisions for an event, returning the exit code
+func dryRun(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	configFile, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening config file: "+err.Error())
+		return 1
+	}
+	defer configFile.Close()
+	cfg, err := config.ReadConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	var event []byte
+	if args[1] == "-" {
+		event, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		event, err = ioutil.ReadFile(args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading event file: "+err.Error())
+		return 1
+	}
+
+	res, err := function.DryRun(cfg, event)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Println(string(res))
+	return 0
+}
+
+// serve runs the function as a standalone HTTP server until it receives a
+// SIGINT or SIGTERM signal, waiting for the requests in progress to finish
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	addr := flags.String("addr", ":8080", "address to listen on")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 30*time.Second, "maximum time to wait for the requests in progress")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return 2
+	}
+
+	server := function.NewServer(*addr, flags.Arg(0))
+	errs := make(chan error, 1)
+	go func() {
+		logging.Default().Info("Listening", logging.Fields{"addr": *addr})
+		errs <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		logging.Default().Error("Error serving", logging.Fields{"error": err})
+		return 1
+	case sig := <-signals:
+		logging.Default().Info("Shutting down", logging.Fields{"signal": sig.String()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil && err != http.ErrServerClosed {
+		logging.Default().Error("Error shutting down", logging.Fields{"error": err})
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MetadataPrefix is the prefix of the user metadata headers in S3, also used
+// by the keys of the user metadata in MinIO events
+const MetadataPrefix = "x-amz-meta-"
+
+// Attributes struct to represent the attributes of a file checked by the
+// size, content type, metadata and tags filters of the outputs
+type Attributes struct {
+	Size        int64
+	ContentType string
+	// User metadata with the normalized keys (see MetadataKey)
+	Metadata map[string]string
+	Tags     map[string]string
+}
+
+// MetadataKey returns the normalized key of a user metadata entry, in lower
+// case and without the "x-amz-meta-" prefix
+func MetadataKey(key string) string {
+	return strings.TrimPrefix(strings.ToLower(key), MetadataPrefix)
+}
+
+// IsMetadataHeader returns true if the key has the "x-amz-meta-" prefix,
+// in any case
+func IsMetadataHeader(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), MetadataPrefix)
+}
+
+// FiltersAttributes returns true if the output has size, content type,
+// metadata or tags filters
+func (o *Output) FiltersAttributes() bool {
+	return o.MinSize > 0 || o.MaxSize > 0 || len(o.ContentType) > 0 || len(o.Metadata) > 0 || len(o.Tags) > 0
+}
+
+// MatchAttributes returns true if the attributes of the file comply with the
+// size, content type, metadata and tags filters of the output. Content types
+// are compared without their parameters (e.g. "; charset=utf-8")
+func (o *Output) MatchAttributes(a *Attributes) bool {
+	if o.MinSize > 0 && a.Size < o.MinSize {
+		return false
+	}
+	if o.MaxSize > 0 && a.Size > o.MaxSize {
+		return false
+	}
+	if len(o.ContentType) > 0 && !anyString(mediaType(a.ContentType), o.ContentType, matchContentType) {
+		return false
+	}
+	for key, value := range o.metadata {
+		if v, ok := a.Metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	for key, value := range o.Tags {
+		if v, ok := a.Tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// compileAttributeFilters checks the size, content type, metadata and tags
+// filters of the output, normalizing the metadata keys
+func (o *Output) compileAttributeFilters(path string, v *validator) {
+	if o.MinSize < 0 {
+		v.add(path+".min_size", "the size can't be negative")
+	}
+	if o.MaxSize < 0 {
+		v.add(path+".max_size", "the size can't be negative")
+	} else if o.MaxSize > 0 && o.MaxSize < o.MinSize {
+		v.add(path+".max_size", "the maximum size must be greater than or equal to the minimum size")
+	}
+	for i, contentType := range o.ContentType {
+		parts := strings.Split(contentType, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(contentType, ";") {
+			v.add(path+".content_type["+strconv.Itoa(i)+"]", "invalid content type '"+contentType+"'")
+		}
+	}
+	for key := range o.Tags {
+		if key == "" {
+			v.add(path+".tags", "the tag keys can't be empty")
+		}
+	}
+	if len(o.Metadata) == 0 {
+		return
+	}
+	o.metadata = make(map[string]string, len(o.Metadata))
+	for key, value := range o.Metadata {
+		if MetadataKey(key) == "" {
+			v.add(path+".metadata", "the metadata keys can't be empty")
+			continue
+		}
+		o.metadata[MetadataKey(key)] = value
+	}
+}
+
+// mediaType returns the content type without parameters, in lower case
+func mediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+}
+
+// matchContentType returns true if the content type matches the filter
+// value, which can use "*" as subtype (e.g. "image/*")
+func matchContentType(contentType, value string) bool {
+	value = strings.ToLower(value)
+	if strings.HasSuffix(value, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(value, "*"))
+	}
+	return contentType == value
+}
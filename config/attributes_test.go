@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputMatchAttributes(t *testing.T) {
+	tests := []struct {
+		output   string
+		matching []Attributes
+		failing  []Attributes
+	}{
+		{
+			output:   `{"min_size": 1024, "max_size": 2048}`,
+			matching: []Attributes{{Size: 1024}, {Size: 2048}},
+			failing:  []Attributes{{Size: 1023}, {Size: 2049}},
+		},
+		{
+			output:   `{"content_type": ["image/*", "Video/MP4"]}`,
+			matching: []Attributes{{ContentType: "image/jpeg"}, {ContentType: "video/mp4; codecs=avc1"}},
+			failing:  []Attributes{{ContentType: "video/x-msvideo"}, {}},
+		},
+		{
+			output: `{"metadata": {"X-Amz-Meta-Quality": "raw"}, "tags": {"Project": "grycap"}}`,
+			matching: []Attributes{
+				{Metadata: map[string]string{"quality": "raw"}, Tags: map[string]string{"Project": "grycap", "other": "value"}},
+			},
+			failing: []Attributes{
+				{Metadata: map[string]string{"quality": "low"}, Tags: map[string]string{"Project": "grycap"}},
+				{Metadata: map[string]string{"quality": "raw"}, Tags: map[string]string{"project": "grycap"}},
+				{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		config := `{
+			"storages": {"local": [{"name": "local", "directory": "/data"}]},
+			"output": [` + strings.TrimSuffix(test.output, "}") + `, "storage_name": "local", "path": "out"}]
+		}`
+		cfg, err := ReadConfig(strings.NewReader(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := &cfg.Outputs[0]
+		for _, attributes := range test.matching {
+			if !output.MatchAttributes(&attributes) {
+				t.Errorf("Error matching attributes %+v with output %s", attributes, test.output)
+			}
+		}
+		for _, attributes := range test.failing {
+			if output.MatchAttributes(&attributes) {
+				t.Errorf("Error rejecting attributes %+v with output %s", attributes, test.output)
+			}
+		}
+	}
+}
+
+func TestMetadataKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+		header   bool
+	}{
+		{"X-Amz-Meta-Quality", "quality", true},
+		{"x-amz-meta-quality", "quality", true},
+		{"Quality", "quality", false},
+		{"content-type", "content-type", false},
+	}
+	for _, test := range tests {
+		if key := MetadataKey(test.key); key != test.expected {
+			t.Errorf("Error normalizing metadata key '%s'. Expected: %s. Received: %s", test.key, test.expected, key)
+		}
+		if IsMetadataHeader(test.key) != test.header {
+			t.Errorf("Error checking the prefix of metadata key '%s'", test.key)
+		}
+	}
+}
+
+func TestValidateAttributeFilters(t *testing.T) {
+	tests := map[string]string{
+		`"min_size": -1`:                                "output[0].min_size: the size can't be negative",
+		`"min_size": 10, "max_size": 5`:                 "output[0].max_size: the maximum size must be greater than or equal to the minimum size",
+		`"content_type": ["image"]`:                     "output[0].content_type[0]: invalid content type 'image'",
+		`"content_type": ["text/plain; charset=utf-8"]`: "output[0].content_type[0]: invalid content type 'text/plain; charset=utf-8'",
+		`"metadata": {"x-amz-meta-": "raw"}`:            "output[0].metadata: the metadata keys can't be empty",
+		`"tags": {"": "raw"}`:                           "output[0].tags: the tag keys can't be empty",
+	}
+
+	for filters, expected := range tests {
+		config := `{
+			"storages": {"local": [{"name": "local", "directory": "/data"}]},
+			"output": [{"storage_name": "local", "path": "out", ` + filters + `}]
+		}`
+		_, err := ReadConfig(strings.NewReader(config))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error reporting invalid filters %s: %v", filters, err)
+		}
+	}
+}
@@ -30,13 +30,128 @@ import (
 type Config struct {
 	StorageProviders map[string]StorageProvider
 	Outputs          []Output
+	// Maximum number of concurrent uploads (0 means no limit)
+	Concurrency int
+	// Destination of the events that couldn't be routed (nil if not set)
+	DeadLetter *DeadLetter
+	// Action applied to the source files once routed (nil if not set)
+	SourceAction *SourceAction
 }
 
 // StorageProvider struct used to load storage providers
 type StorageProvider struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Auth Auth   `json:"auth"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Auth  Auth        `json:"auth"`
+	Retry RetryPolicy `json:"retry"`
+	TLS   TLS         `json:"tls"`
+	// Bucket addressing style of S3 compatible providers ("path" or "virtual")
+	Addressing string `json:"addressing"`
+	// Multipart transfer settings of S3 compatible providers
+	Multipart Multipart `json:"multipart"`
+	// Root directory of local providers
+	Directory string `json:"directory"`
+}
+
+// TLS struct used to load the TLS settings of storage providers.
+// Certificates and keys can be set as PEM content or as file paths
+type TLS struct {
+	// Use TLS (if not set, it depends on the endpoint scheme and the provider type)
+	Enabled            *bool  `json:"enabled"`
+	CABundle           string `json:"ca_bundle"`
+	ClientCert         string `json:"client_cert"`
+	ClientKey          string `json:"client_key"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// Multipart struct used to load the multipart transfer settings of S3
+// compatible providers. Files larger than a part are uploaded and downloaded
+// in parts, transferring several of them concurrently
+type Multipart struct {
+	// Size of the parts in MiB (0 means the default, 5 MiB)
+	PartSize int64 `json:"part_size"`
+	// Number of parts transferred concurrently for each file (0 means the
+	// default, 5). Files are downloaded with a single request if it's 1
+	Concurrency int `json:"concurrency"`
+}
+
+// RetryPolicy struct used to load the retry policy of storage providers
+type RetryPolicy struct {
+	// Maximum number of attempts of each operation (0 or 1 means no retries)
+	Attempts int `json:"attempts"`
+	// Backoff before the first retry and maximum backoff in milliseconds
+	InitialBackoff int `json:"initial_backoff"`
+	MaxBackoff     int `json:"max_backoff"`
+	// Factor applied to the backoff after each retry
+	Multiplier float64 `json:"multiplier"`
+	// Random variation of the backoff, as a fraction of it (from 0 to 1)
+	Jitter float64 `json:"jitter"`
+	// Error classes that are retried ("network", "timeout", "throttling" and "server")
+	RetryOn []string `json:"retry_on"`
+}
+
+// DeadLetter struct used to load the dead-letter destination
+type DeadLetter struct {
+	StorageProviderName string `json:"storage_name"`
+	Path                string `json:"path"`
+}
+
+// Source action values
+const (
+	SourceActionDelete = "delete"
+	SourceActionMove   = "move"
+)
+
+// SourceAction struct used to load the action applied to the source files
+// after uploading them successfully to all the matching outputs
+type SourceAction struct {
+	// "delete" or "move"
+	Action string `json:"action"`
+	// Archive path in the source storage provider of the moved files
+	Path string `json:"path"`
+	// Parsed path template (nil if the path doesn't contain variables)
+	template []templatePart
+}
+
+// Metadata modes of the outputs
+const (
+	MetadataPreserve = "preserve"
+	MetadataDrop     = "drop"
+)
+
+// WriteMetadata struct used to load the metadata and tags written with the
+// files of an output
+type WriteMetadata struct {
+	// "preserve" (default) keeps the metadata and tags of the source file,
+	// "drop" discards them
+	Mode string `json:"mode"`
+	// Values overriding the ones of the source file
+	ContentType     string            `json:"content_type"`
+	ContentEncoding string            `json:"content_encoding"`
+	CacheControl    string            `json:"cache_control"`
+	UserMetadata    map[string]string `json:"user_metadata"`
+	// Tags added to the files, whose values can use the path template variables
+	Tags map[string]string `json:"tags"`
+	// Parsed tag values (nil if they don't contain variables)
+	tagTemplates map[string][]templatePart
+}
+
+// Checksum storage modes of the outputs
+const (
+	ChecksumMetadata = "metadata"
+	ChecksumSidecar  = "sidecar"
+)
+
+// Checksum struct used to load the checksum options of an output
+type Checksum struct {
+	// Fail the upload if the checksums of the downloaded file don't match
+	// the source ETag (if it's an MD5 digest) or "sha256" and "crc32c" metadata
+	Verify bool `json:"verify"`
+	// Store the SHA-256 checksum of the files as "metadata" or as a
+	// "sidecar" file (<path>.sha256). Empty doesn't store it
+	Store string `json:"store"`
+	// Compute also the CRC32C checksum (stored only as metadata)
+	CRC32C bool `json:"crc32c"`
 }
 
 // Auth struct used to load storage provider authentication
@@ -46,6 +161,7 @@ type Auth struct {
 	Endpoint  string `json:"endpoint"`
 	Token     string `json:"token"`
 	Space     string `json:"space"`
+	Region    string `json:"region"`
 }
 
 // Output struct used to load output configurations
@@ -54,17 +170,47 @@ type Output struct {
 	Path                string   `json:"path"`
 	Suffix              []string `json:"suffix"`
 	Prefix              []string `json:"prefix"`
+	Regex               []string `json:"regex"`
+	Glob                []string `json:"glob"`
+	Exclude             []string `json:"exclude"`
+	// Maximum duration of the upload in seconds (0 means no limit)
+	Timeout int `json:"timeout"`
+	// Delete the uploaded files when they are removed from the source
+	Mirror bool `json:"mirror"`
+	// Size limits of the files in bytes (0 means no limit)
+	MinSize int64 `json:"min_size"`
+	MaxSize int64 `json:"max_size"`
+	// Content types of the files (e.g. "image/jpeg" or "image/*")
+	ContentType []string `json:"content_type"`
+	// User metadata and tags that the files must have
+	Metadata map[string]string `json:"metadata"`
+	Tags     map[string]string `json:"tags"`
+	// Metadata and tags written with the files (nil preserves the source ones)
+	WriteMetadata *WriteMetadata `json:"write_metadata"`
+	// Checksums computed while uploading the files (nil doesn't compute them)
+	Checksum *Checksum `json:"checksum"`
+	// Compiled regex, glob and exclude patterns
+	matchers matchers
+	// Metadata filter with the normalized keys
+	metadata map[string]string
+	// Parsed path template (nil if the path doesn't contain variables)
+	template []templatePart
 }
 
 type storages struct {
 	S3      []StorageProvider `json:"s3"`
 	Minio   []StorageProvider `json:"minio"`
 	Onedata []StorageProvider `json:"onedata"`
+	Local   []StorageProvider `json:"local"`
 }
 
 type rawConfig struct {
-	Storages storages `json:"storages"`
-	Outputs  []Output `json:"output"`
+	Storages    storages    `json:"storages"`
+	Outputs     []Output    `json:"output"`
+	Concurrency int         `json:"concurrency"`
+	DeadLetter  *DeadLetter `json:"dead_letter"`
+	// Action applied to the source files once routed
+	SourceAction *SourceAction `json:"source_action"`
 }
 
 func convertStorages(s *storages) map[string]StorageProvider {
@@ -87,6 +233,12 @@ func convertStorages(s *storages) map[string]StorageProvider {
 			storageProviders[onedataProv.Name] = onedataProv
 		}
 	}
+	if s.Local != nil {
+		for _, localProv := range s.Local {
+			localProv.Type = "local"
+			storageProviders[localProv.Name] = localProv
+		}
+	}
 	return storageProviders
 }
 
@@ -99,11 +251,17 @@ func ReadConfig(fileContent io.Reader) (*Config, error) {
 	var c rawConfig
 	err = json.Unmarshal(jsonConfig, &c)
 	if err != nil {
-		return nil, errors.New("Invalid config format")
+		return nil, errors.New("Invalid config format: " + err.Error())
+	}
+	if err = c.validate(); err != nil {
+		return nil, err
 	}
 	config := &Config{
 		StorageProviders: convertStorages(&c.Storages),
 		Outputs:          c.Outputs,
+		Concurrency:      c.Concurrency,
+		DeadLetter:       c.DeadLetter,
+		SourceAction:     c.SourceAction,
 	}
 	return config, nil
 }
@@ -46,9 +46,11 @@ func TestReadConfig(t *testing.T) {
 				"path": "scar-ffmpeg/scar-batch-ffmpeg-split",
 				"suffix": [
 					"wav"
-				]
+				],
+				"timeout": 60
 				}
 			],
+			"concurrency": 4,
 			"storages": {
 				"s3": [
 				{
@@ -97,8 +99,10 @@ func TestReadConfig(t *testing.T) {
 					StorageProviderName: "minio-bucket",
 					Path:                "scar-ffmpeg/scar-batch-ffmpeg-split",
 					Suffix:              []string{"wav"},
+					Timeout:             60,
 				},
 			},
+			Concurrency: 4,
 		},
 	}
 
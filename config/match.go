@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matchers struct to store the compiled patterns of an output
+type matchers struct {
+	regex   []*regexp.Regexp
+	glob    []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// Match returns true if the object key complies with all the filters of the
+// output. Each filter matches if the key matches any of its patterns (or if
+// it has no patterns), while the key is rejected if it matches any of the
+// exclude patterns
+func (o *Output) Match(key string) bool {
+	if len(o.Prefix) > 0 && !anyString(key, o.Prefix, strings.HasPrefix) {
+		return false
+	}
+	if len(o.Suffix) > 0 && !anyString(key, o.Suffix, strings.HasSuffix) {
+		return false
+	}
+	if len(o.matchers.regex) > 0 && !anyRegexp(key, o.matchers.regex) {
+		return false
+	}
+	if len(o.matchers.glob) > 0 && !anyRegexp(key, o.matchers.glob) {
+		return false
+	}
+	if anyRegexp(key, o.matchers.exclude) {
+		return false
+	}
+	return true
+}
+
+// compileMatchers compiles the regex, glob and exclude patterns of the output,
+// reporting the invalid ones to the validator
+func (o *Output) compileMatchers(path string, v *validator) {
+	var m matchers
+	for i, pattern := range o.Regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.add(path+".regex["+strconv.Itoa(i)+"]", "invalid regex '"+pattern+"': "+err.Error())
+			continue
+		}
+		m.regex = append(m.regex, re)
+	}
+	for i, pattern := range o.Glob {
+		re, err := compileGlob(pattern)
+		if err != nil {
+			v.add(path+".glob["+strconv.Itoa(i)+"]", "invalid glob '"+pattern+"': "+err.Error())
+			continue
+		}
+		m.glob = append(m.glob, re)
+	}
+	for i, pattern := range o.Exclude {
+		re, err := compileGlob(pattern)
+		if err != nil {
+			v.add(path+".exclude["+strconv.Itoa(i)+"]", "invalid glob '"+pattern+"': "+err.Error())
+			continue
+		}
+		m.exclude = append(m.exclude, re)
+	}
+	o.matchers = m
+}
+
+func anyString(key string, values []string, match func(s, value string) bool) bool {
+	for _, value := range values {
+		if match(key, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyRegexp(key string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob converts a glob pattern into a regular expression matching the
+// whole object key. "*" matches any sequence of characters except "/", "**"
+// any sequence including "/" ("**/" matches zero or more folders), "?" any
+// single character except "/" and "[...]" any character of the class
+// ("[!...]" negates it)
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("unclosed character class at position " + strconv.Itoa(i))
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputMatch(t *testing.T) {
+	tests := []struct {
+		output   Output
+		matching []string
+		failing  []string
+	}{
+		{
+			output:   Output{},
+			matching: []string{"file.txt", "folder/file.avi"},
+		},
+		{
+			output:   Output{Prefix: []string{"video-", "audio-"}, Suffix: []string{"avi", "wav"}},
+			matching: []string{"video-1.avi", "audio-1.wav"},
+			failing:  []string{"video-1.mp4", "image-1.avi"},
+		},
+		{
+			output:   Output{Regex: []string{`^camera-[0-9]+/.*\.(mp4|avi)$`}},
+			matching: []string{"camera-1/video.mp4", "camera-23/day/video.avi"},
+			failing:  []string{"camera-a/video.mp4", "camera-1/video.wav", "x/camera-1/video.mp4"},
+		},
+		{
+			output:   Output{Glob: []string{"**/raw/*.wav"}},
+			matching: []string{"raw/audio.wav", "a/b/raw/audio.wav"},
+			failing:  []string{"raw/sub/audio.wav", "a/raw/audio.mp3", "a/notraw/audio.wav"},
+		},
+		{
+			output:   Output{Glob: []string{"data-?/[!x]*.csv", "docs/**"}},
+			matching: []string{"data-1/a.csv", "docs/a/b/c.pdf"},
+			failing:  []string{"data-12/a.csv", "data-1/x.csv", "data-1/a.csv.bak"},
+		},
+		{
+			output:   Output{Suffix: []string{"wav"}, Exclude: []string{"**/tmp/**", "*.part.wav"}},
+			matching: []string{"audio.wav", "a/audio.wav"},
+			failing:  []string{"tmp/audio.wav", "a/tmp/b/audio.wav", "audio.part.wav"},
+		},
+	}
+
+	for i, test := range tests {
+		v := &validator{}
+		if test.output.compileMatchers("output", v); v.err() != nil {
+			t.Fatalf("Error compiling matchers of test %d: %v", i, v.err())
+		}
+		for _, key := range test.matching {
+			if !test.output.Match(key) {
+				t.Errorf("Test %d: key '%s' should match", i, key)
+			}
+		}
+		for _, key := range test.failing {
+			if test.output.Match(key) {
+				t.Errorf("Test %d: key '%s' should not match", i, key)
+			}
+		}
+	}
+}
+
+func TestReadConfigInvalidPatterns(t *testing.T) {
+	tests := []string{
+		`{"output": [{"storage_name": "s", "path": "p", "regex": ["(unclosed"]}]}`,
+		`{"output": [{"storage_name": "s", "path": "p", "glob": ["[unclosed"]}]}`,
+		`{"output": [{"storage_name": "s", "path": "p"}, {"storage_name": "s", "path": "p", "exclude": ["a[b"]}]}`,
+	}
+
+	for _, test := range tests {
+		if _, err := ReadConfig(strings.NewReader(test)); err == nil {
+			t.Errorf("Error reporting invalid pattern in config: %s", test)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+)
+
+// MaxTags is the maximum number of tags of an object in S3
+const MaxTags = 10
+
+// PreserveMetadata returns true if the metadata and tags of the source files
+// are kept in the output
+func (o *Output) PreserveMetadata() bool {
+	return o.WriteMetadata == nil || o.WriteMetadata.Mode != MetadataDrop
+}
+
+// UserMetadata returns the user metadata overridden in the output, with the
+// normalized keys (see MetadataKey)
+func (o *Output) UserMetadata() map[string]string {
+	if o.WriteMetadata == nil || len(o.WriteMetadata.UserMetadata) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string, len(o.WriteMetadata.UserMetadata))
+	for key, value := range o.WriteMetadata.UserMetadata {
+		metadata[MetadataKey(key)] = value
+	}
+	return metadata
+}
+
+// ExtraTags returns the tags added to the files of the output, replacing the
+// template variables of their values
+func (o *Output) ExtraTags(v *PathVars) map[string]string {
+	if o.WriteMetadata == nil || len(o.WriteMetadata.Tags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(o.WriteMetadata.Tags))
+	for key, value := range o.WriteMetadata.Tags {
+		if template, ok := o.WriteMetadata.tagTemplates[key]; ok {
+			value = renderValue(template, v)
+		}
+		tags[key] = value
+	}
+	return tags
+}
+
+// compileWriteMetadata checks the metadata options of the output, parsing
+// the variables of the tag values
+func (o *Output) compileWriteMetadata(path string, v *validator) {
+	m := o.WriteMetadata
+	if m == nil {
+		return
+	}
+	path += ".write_metadata"
+	if m.Mode != "" && m.Mode != MetadataPreserve && m.Mode != MetadataDrop {
+		v.add(path+".mode", "unknown mode '"+m.Mode+"', valid modes are: preserve, drop")
+	}
+	for key := range m.UserMetadata {
+		if MetadataKey(key) == "" {
+			v.add(path+".user_metadata", "the metadata keys can't be empty")
+		}
+	}
+	if len(m.Tags) > MaxTags {
+		v.add(path+".tags", "at most "+strconv.Itoa(MaxTags)+" tags can be added")
+	}
+	m.tagTemplates = make(map[string][]templatePart)
+	for key, value := range m.Tags {
+		if key == "" {
+			v.add(path+".tags", "the tag keys can't be empty")
+			continue
+		}
+		if template := compilePathTemplate(value, path+".tags."+key, v); template != nil {
+			m.tagTemplates[key] = template
+		}
+	}
+}
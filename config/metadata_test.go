@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetadata(t *testing.T) {
+	config := `{
+		"storages": {"local": [{"name": "local", "directory": "/data"}]},
+		"output": [
+			{"storage_name": "local", "path": "preserved"},
+			{"storage_name": "local", "path": "dropped", "write_metadata": {
+				"mode": "drop",
+				"user_metadata": {"X-Amz-Meta-Quality": "raw"},
+				"tags": {"routed-by": "multi-out-faas", "source-key": "{bucket}/{key}"}
+			}}
+		]
+	}`
+	cfg, err := ReadConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := &PathVars{Key: "a/b.txt", Bucket: "input"}
+
+	preserved := &cfg.Outputs[0]
+	if !preserved.PreserveMetadata() || preserved.UserMetadata() != nil || preserved.ExtraTags(vars) != nil {
+		t.Error("Error loading the default metadata options")
+	}
+
+	dropped := &cfg.Outputs[1]
+	if dropped.PreserveMetadata() {
+		t.Error("Error loading the drop metadata mode")
+	}
+	if metadata := dropped.UserMetadata(); !reflect.DeepEqual(metadata, map[string]string{"quality": "raw"}) {
+		t.Errorf("Error normalizing the user metadata: %v", metadata)
+	}
+	expectedTags := map[string]string{"routed-by": "multi-out-faas", "source-key": "input/a/b.txt"}
+	if tags := dropped.ExtraTags(vars); !reflect.DeepEqual(tags, expectedTags) {
+		t.Errorf("Error rendering the tags. Expected: %v. Received: %v", expectedTags, tags)
+	}
+}
+
+func TestValidateWriteMetadata(t *testing.T) {
+	tests := map[string]string{
+		`{"mode": "copy"}`:                  "output[0].write_metadata.mode: unknown mode 'copy', valid modes are: preserve, drop",
+		`{"user_metadata": {"": "raw"}}`:    "output[0].write_metadata.user_metadata: the metadata keys can't be empty",
+		`{"tags": {"": "raw"}}`:             "output[0].write_metadata.tags: the tag keys can't be empty",
+		`{"tags": {"source-key": "{day}"}}`: "output[0].write_metadata.tags.source-key: unknown variable 'day' in path template",
+		`{"tags": {"1": "", "2": "", "3": "", "4": "", "5": "", "6": "", "7": "", "8": "", "9": "", "10": "", "11": ""}}`: "output[0].write_metadata.tags: at most 10 tags can be added",
+	}
+
+	for writeMetadata, expected := range tests {
+		config := `{
+			"storages": {"local": [{"name": "local", "directory": "/data"}]},
+			"output": [{"storage_name": "local", "path": "out", "write_metadata": ` + writeMetadata + `}]
+		}`
+		_, err := ReadConfig(strings.NewReader(config))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error reporting invalid metadata options %s: %v", writeMetadata, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayout is used by the event_time variable when no layout is set
+const defaultTimeLayout = "2006-01-02T15:04:05Z07:00"
+
+// PathVars struct with the values of the variables that can be used in the
+// path template of the outputs
+type PathVars struct {
+	Key       string
+	Bucket    string
+	Source    string
+	EventTime time.Time
+}
+
+// templatePart struct to represent a literal string or a variable of a template
+type templatePart struct {
+	literal  string
+	variable string
+	// layout of the event_time variable
+	layout string
+}
+
+var templateVariables = map[string]func(v *PathVars, layout string) string{
+	"key": func(v *PathVars, layout string) string {
+		return v.Key
+	},
+	"dir": func(v *PathVars, layout string) string {
+		if dir := path.Dir(v.Key); dir != "." {
+			return dir
+		}
+		return ""
+	},
+	"basename": func(v *PathVars, layout string) string {
+		return path.Base(v.Key)
+	},
+	"stem": func(v *PathVars, layout string) string {
+		base := path.Base(v.Key)
+		return strings.TrimSuffix(base, path.Ext(base))
+	},
+	"ext": func(v *PathVars, layout string) string {
+		return strings.TrimPrefix(path.Ext(v.Key), ".")
+	},
+	"bucket": func(v *PathVars, layout string) string {
+		return v.Bucket
+	},
+	"source": func(v *PathVars, layout string) string {
+		return v.Source
+	},
+	"event_time": func(v *PathVars, layout string) string {
+		return v.EventTime.Format(layout)
+	},
+}
+
+// DestinationPath returns the path where a file is uploaded in the output.
+// If the output path doesn't contain template variables, the file is stored
+// inside it using its base name.
+// Returns an error if the path escapes the output (see renderTemplate)
+func (o *Output) DestinationPath(v *PathVars) (string, error) {
+	if o.template == nil {
+		base := path.Base(v.Key)
+		if err := checkSegments(base); err != nil {
+			return "", err
+		}
+		return o.Path + "/" + base, nil
+	}
+	return renderTemplate(o.template, v)
+}
+
+// ArchivePath returns the path where a source file is moved. If the path
+// doesn't contain template variables, the file is stored inside it keeping
+// its key.
+// Returns an error if the path escapes the archive path (see renderTemplate)
+func (a *SourceAction) ArchivePath(v *PathVars) (string, error) {
+	if a.template == nil {
+		if err := checkSegments(v.Key); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(a.Path, "/") + "/" + v.Key, nil
+	}
+	return renderTemplate(a.template, v)
+}
+
+// usesVariable returns true if the path template of the output uses the variable
+func (o *Output) usesVariable(name string) bool {
+	for _, part := range o.template {
+		if part.variable == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compileTemplate parses the variables of the output path, reporting the
+// invalid ones to the validator
+func (o *Output) compileTemplate(path string, v *validator) {
+	o.template = compilePathTemplate(o.Path, path+".path", v)
+}
+
+// compilePathTemplate parses the variables of a path, reporting the invalid
+// ones to the validator. Returns nil if the path doesn't contain variables
+func compilePathTemplate(s, path string, v *validator) []templatePart {
+	if !strings.ContainsAny(s, "{}") {
+		return nil
+	}
+	template, err := parseTemplate(s)
+	if err != nil {
+		v.add(path, err.Error())
+		return nil
+	}
+	return template
+}
+
+// renderTemplate replaces the variables of a path template with their values.
+// Object keys can contain ".." segments, so the paths with them are rejected
+// to prevent writing outside the output, as well as the ones whose bucket
+// differs from the literal bucket of the template (e.g. "bucket/{key}")
+func renderTemplate(template []templatePart, v *PathVars) (string, error) {
+	rendered := renderValue(template, v)
+	if err := checkSegments(rendered); err != nil {
+		return "", err
+	}
+	// Remove the empty folders generated by empty variables (e.g. {dir})
+	dst := strings.TrimPrefix(path.Clean("/"+rendered), "/")
+	if bucket := templateBucket(template); bucket != "" && strings.SplitN(dst, "/", 2)[0] != bucket {
+		return "", errors.New("the path '" + dst + "' is outside the bucket '" + bucket + "'")
+	}
+	return dst, nil
+}
+
+// checkSegments returns an error if a path contains ".." segments
+func checkSegments(p string) error {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return errors.New("the path '" + p + "' can't contain '..' segments")
+		}
+	}
+	return nil
+}
+
+// templateBucket returns the first segment of a template if it doesn't
+// contain variables, or an empty string otherwise
+func templateBucket(template []templatePart) string {
+	if len(template) == 0 || template[0].variable != "" {
+		return ""
+	}
+	literal := strings.TrimLeft(template[0].literal, "/")
+	if i := strings.IndexByte(literal, '/'); i > 0 {
+		return literal[:i]
+	}
+	return ""
+}
+
+// renderValue replaces the variables of a template with their values. The
+// separator before an empty {ext} variable is removed (e.g. "{stem}.{ext}")
+func renderValue(template []templatePart, v *PathVars) string {
+	var sb strings.Builder
+	for _, part := range template {
+		if part.variable == "" {
+			sb.WriteString(part.literal)
+			continue
+		}
+		value := templateVariables[part.variable](v, part.layout)
+		if part.variable == "ext" && value == "" {
+			if s := sb.String(); strings.HasSuffix(s, ".") {
+				sb.Reset()
+				sb.WriteString(strings.TrimSuffix(s, "."))
+			}
+		}
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
+
+// parseTemplate splits a template into its literal strings and variables
+// ("{name}" or "{name:format}")
+func parseTemplate(s string) ([]templatePart, error) {
+	var parts []templatePart
+	for s != "" {
+		open := strings.IndexAny(s, "{}")
+		if open < 0 {
+			parts = append(parts, templatePart{literal: s})
+			break
+		}
+		if s[open] == '}' {
+			return nil, errors.New("unexpected '}' in path template")
+		}
+		if open > 0 {
+			parts = append(parts, templatePart{literal: s[:open]})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, errors.New("unclosed '{' in path template")
+		}
+		name := s[open+1 : open+end]
+		var layout string
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name, layout = name[:i], name[i+1:]
+		}
+		if _, ok := templateVariables[name]; !ok {
+			return nil, errors.New("unknown variable '" + name + "' in path template")
+		}
+		if layout != "" && name != "event_time" {
+			return nil, errors.New("the variable '" + name + "' doesn't accept a format")
+		}
+		if name == "event_time" && layout == "" {
+			layout = defaultTimeLayout
+		}
+		parts = append(parts, templatePart{variable: name, layout: layout})
+		s = s[open+end+1:]
+	}
+	return parts, nil
+}
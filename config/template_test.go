@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDestinationPath(t *testing.T) {
+	vars := &PathVars{
+		Key:       "camera-1/2019/video.final.mp4",
+		Bucket:    "input",
+		Source:    "minio",
+		EventTime: time.Date(2019, 2, 23, 11, 40, 46, 0, time.UTC),
+	}
+
+	tests := map[string]string{
+		"output":                                "output/video.final.mp4",
+		"output/":                               "output//video.final.mp4",
+		"output/{key}":                          "output/camera-1/2019/video.final.mp4",
+		"output/{dir}/{stem}-processed.{ext}":   "output/camera-1/2019/video.final-processed.mp4",
+		"{bucket}-copy/{source}/{basename}":     "input-copy/minio/video.final.mp4",
+		"archive/{event_time:2006/01/02}/{key}": "archive/2019/02/23/camera-1/2019/video.final.mp4",
+		"archive/{event_time}/{basename}":       "archive/2019-02-23T11:40:46Z/video.final.mp4",
+		"/output//{dir}/{basename}":             "output/camera-1/2019/video.final.mp4",
+	}
+
+	for template, expected := range tests {
+		output := Output{Path: template}
+		v := &validator{}
+		output.compileTemplate("output", v)
+		if err := v.err(); err != nil {
+			t.Fatalf("Error parsing template '%s': %v", template, err)
+		}
+		if dst, err := output.DestinationPath(vars); err != nil || dst != expected {
+			t.Errorf("Error rendering template '%s': got '%s' (%v), expected '%s'", template, dst, err, expected)
+		}
+	}
+
+	// Empty folders are removed
+	output := Output{Path: "output/{dir}/{basename}"}
+	output.compileTemplate("output", &validator{})
+	if dst, _ := output.DestinationPath(&PathVars{Key: "file.txt"}); dst != "output/file.txt" {
+		t.Errorf("Error removing empty folders: got '%s'", dst)
+	}
+
+	// The extension separator is removed for files without extension
+	output = Output{Path: "output/{stem}-processed.{ext}"}
+	output.compileTemplate("output", &validator{})
+	if dst, _ := output.DestinationPath(&PathVars{Key: "dir/noext"}); dst != "output/noext-processed" {
+		t.Errorf("Error removing the extension separator: got '%s'", dst)
+	}
+}
+
+func TestDestinationPathTraversal(t *testing.T) {
+	tests := map[string]string{
+		"out-bucket/{key}":           "../../victim-bucket/evil.txt",
+		"out-bucket/{dir}/file.txt":  "../../victim-bucket/evil.txt",
+		"out-bucket/data/{basename}": "dir/..",
+		"{bucket}/{key}":             "a/../../victim-bucket/evil.txt",
+		"out-bucket":                 "dir/..",
+	}
+
+	for template, key := range tests {
+		output := Output{Path: template}
+		output.compileTemplate("output", &validator{})
+		if dst, err := output.DestinationPath(&PathVars{Key: key, Bucket: "input"}); err == nil {
+			t.Errorf("Error rejecting path '%s' for key '%s': got '%s'", template, key, dst)
+		}
+	}
+
+	// The bucket can't change even without ".." segments
+	output := Output{Path: "out-bucket{dir}/{basename}"}
+	output.compileTemplate("output", &validator{})
+	if dst, err := output.DestinationPath(&PathVars{Key: "-other/file.txt"}); err != nil || dst != "out-bucket-other/file.txt" {
+		t.Errorf("Error rendering path with variables in the bucket: got '%s' (%v)", dst, err)
+	}
+	output = Output{Path: "/out-bucket/{key}"}
+	output.compileTemplate("output", &validator{})
+	if dst, err := output.DestinationPath(&PathVars{Key: "/videos/file.txt"}); err != nil || dst != "out-bucket/videos/file.txt" {
+		t.Errorf("Error rendering path with leading slashes: got '%s' (%v)", dst, err)
+	}
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	for _, template := range []string{"output/{unknown}", "output/{key", "output/key}", "output/{key:2006}"} {
+		output := Output{Path: template}
+		v := &validator{}
+		if output.compileTemplate("output", v); v.err() == nil {
+			t.Errorf("Error reporting invalid template '%s'", template)
+		}
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	vars := &PathVars{Key: "camera-1/video.mp4", Bucket: "input"}
+
+	tests := map[string]string{
+		"input/archive":                 "input/archive/camera-1/video.mp4",
+		"input/archive/":                "input/archive/camera-1/video.mp4",
+		"{bucket}/processed/{basename}": "input/processed/video.mp4",
+	}
+
+	for template, expected := range tests {
+		action := SourceAction{Action: SourceActionMove, Path: template}
+		v := &validator{}
+		action.template = compilePathTemplate(action.Path, "source_action.path", v)
+		if err := v.err(); err != nil {
+			t.Fatalf("Error parsing template '%s': %v", template, err)
+		}
+		if dst, err := action.ArchivePath(vars); err != nil || dst != expected {
+			t.Errorf("Error rendering archive path '%s': got '%s' (%v), expected '%s'", template, dst, err, expected)
+		}
+	}
+
+	action := SourceAction{Action: SourceActionMove, Path: "input/archive"}
+	if dst, err := action.ArchivePath(&PathVars{Key: "../../victim/evil.txt"}); err == nil {
+		t.Errorf("Error rejecting archive path outside the archive: got '%s'", dst)
+	}
+}
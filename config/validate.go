@@ -0,0 +1,279 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Problem struct to represent an error found in the configuration,
+// located by its JSON path (e.g. "output[1].storage_name")
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// ValidationError struct to report all the problems found in the configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.Path + ": " + p.Message
+	}
+	return "Invalid config:\n" + strings.Join(lines, "\n")
+}
+
+// validator accumulates the problems found during the validation
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(path, message string) {
+	v.problems = append(v.problems, Problem{Path: path, Message: message})
+}
+
+// err returns a *ValidationError with the problems found or nil if there are none
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validate checks the raw configuration, reporting every problem found.
+// The patterns of the outputs are compiled during the validation
+func (c *rawConfig) validate() error {
+	v := &validator{}
+
+	// Storage providers
+	types := make(map[string]string)
+	checkStorages := func(storageType string, providers []StorageProvider) {
+		for i, provider := range providers {
+			path := "storages." + storageType + "[" + strconv.Itoa(i) + "]"
+			if provider.Name == "" {
+				v.add(path+".name", "the name is required")
+			} else if previous, ok := types[provider.Name]; ok {
+				v.add(path+".name", "the name '"+provider.Name+"' is already used by a "+previous+" storage provider")
+			} else {
+				types[provider.Name] = storageType
+			}
+			validateAuth(v, storageType, path+".auth", &provider.Auth)
+			validateRetry(v, path+".retry", &provider.Retry)
+			if (provider.TLS.ClientCert == "") != (provider.TLS.ClientKey == "") {
+				v.add(path+".tls", "both client_cert and client_key must be set")
+			}
+			if storageType == "local" && provider.Directory == "" {
+				v.add(path+".directory", "the directory is required")
+			}
+			validateMultipart(v, storageType, path+".multipart", &provider.Multipart)
+			if provider.Addressing != "" {
+				if storageType != "s3" && storageType != "minio" {
+					v.add(path+".addressing", "the addressing style is only valid for S3 compatible providers")
+				} else if provider.Addressing != "path" && provider.Addressing != "virtual" {
+					v.add(path+".addressing", "unknown addressing style '"+provider.Addressing+"', valid styles are: path, virtual")
+				}
+			}
+		}
+	}
+	checkStorages("s3", c.Storages.S3)
+	checkStorages("minio", c.Storages.Minio)
+	checkStorages("onedata", c.Storages.Onedata)
+	checkStorages("local", c.Storages.Local)
+
+	// Outputs
+	if len(c.Outputs) == 0 {
+		v.add("output", "at least one output is required")
+	}
+	for i := range c.Outputs {
+		output := &c.Outputs[i]
+		path := "output[" + strconv.Itoa(i) + "]"
+		storageType, ok := types[output.StorageProviderName]
+		if output.StorageProviderName == "" {
+			v.add(path+".storage_name", "the storage name is required")
+		} else if !ok {
+			v.add(path+".storage_name", "undefined storage provider '"+output.StorageProviderName+"'")
+		}
+		if (storageType == "s3" || storageType == "minio") && strings.Trim(output.Path, "/") == "" {
+			v.add(path+".path", "the path must start with the bucket name")
+		}
+		if output.Timeout < 0 {
+			v.add(path+".timeout", "the timeout can't be negative")
+		}
+		output.compileMatchers(path, v)
+		output.compileAttributeFilters(path, v)
+		output.compileWriteMetadata(path, v)
+		validateChecksum(v, storageType, path+".checksum", output.Checksum)
+		output.compileTemplate(path, v)
+		if output.Mirror && output.usesVariable("event_time") {
+			v.add(path+".mirror", "outputs with the {event_time} variable in their path can't be mirrored")
+		}
+	}
+
+	if c.DeadLetter != nil {
+		storageType, ok := types[c.DeadLetter.StorageProviderName]
+		if c.DeadLetter.StorageProviderName == "" {
+			v.add("dead_letter.storage_name", "the storage name is required")
+		} else if !ok {
+			v.add("dead_letter.storage_name", "undefined storage provider '"+c.DeadLetter.StorageProviderName+"'")
+		}
+		if (storageType == "s3" || storageType == "minio") && strings.Trim(c.DeadLetter.Path, "/") == "" {
+			v.add("dead_letter.path", "the path must start with the bucket name")
+		}
+	}
+
+	if a := c.SourceAction; a != nil {
+		switch a.Action {
+		case SourceActionDelete:
+			if a.Path != "" {
+				v.add("source_action.path", "the path is only valid for the move action")
+			}
+		case SourceActionMove:
+			if strings.Trim(a.Path, "/") == "" {
+				v.add("source_action.path", "the archive path is required")
+			}
+			a.template = compilePathTemplate(a.Path, "source_action.path", v)
+		default:
+			v.add("source_action.action", "unknown action '"+a.Action+"', valid actions are: delete, move")
+		}
+		// Deleting or moving the source files generates their removed events,
+		// which would delete the routed copies from the mirrored outputs
+		for i := range c.Outputs {
+			if c.Outputs[i].Mirror {
+				v.add("source_action", "the source files can't be deleted or moved when output["+strconv.Itoa(i)+"] is mirrored")
+			}
+		}
+	}
+
+	if c.Concurrency < 0 {
+		v.add("concurrency", "the concurrency can't be negative")
+	}
+
+	return v.err()
+}
+
+// validateAuth checks the authentication fields required by each storage type
+func validateAuth(v *validator, storageType, path string, auth *Auth) {
+	switch storageType {
+	case "s3":
+		if (auth.AccessKey == "") != (auth.SecretKey == "") {
+			v.add(path, "both access_key and secret_key must be set (or none to use the default credentials)")
+		}
+	case "minio":
+		if auth.Endpoint == "" {
+			v.add(path+".endpoint", "the endpoint is required")
+		}
+		if auth.AccessKey == "" {
+			v.add(path+".access_key", "the access key is required")
+		}
+		if auth.SecretKey == "" {
+			v.add(path+".secret_key", "the secret key is required")
+		}
+	case "onedata":
+		if auth.Endpoint == "" {
+			v.add(path+".endpoint", "the Oneprovider endpoint is required")
+		}
+		if auth.Token == "" {
+			v.add(path+".token", "the token is required")
+		}
+		if auth.Space == "" {
+			v.add(path+".space", "the space is required")
+		}
+	}
+}
+
+// Limits of the part size in MiB, as allowed by S3
+const (
+	MinPartSize = 5
+	MaxPartSize = 5 * 1024
+)
+
+// validateMultipart checks the multipart settings of a storage provider
+func validateMultipart(v *validator, storageType, path string, m *Multipart) {
+	if *m == (Multipart{}) {
+		return
+	}
+	if storageType != "s3" && storageType != "minio" {
+		v.add(path, "the multipart settings are only valid for S3 compatible providers")
+		return
+	}
+	if m.PartSize != 0 && (m.PartSize < MinPartSize || m.PartSize > MaxPartSize) {
+		v.add(path+".part_size", "the part size must be between "+strconv.Itoa(MinPartSize)+" and "+strconv.Itoa(MaxPartSize)+" MiB")
+	}
+	if m.Concurrency < 0 {
+		v.add(path+".concurrency", "the concurrency can't be negative")
+	}
+}
+
+// RetryClasses are the error classes that can be retried (all of them are
+// retried by default)
+var RetryClasses = []string{"network", "timeout", "throttling", "server"}
+
+// validateChecksum checks the checksum options of an output. The checksums
+// are stored as metadata by copying the uploaded file over itself, which is
+// only supported by S3 and MinIO
+func validateChecksum(v *validator, storageType, path string, c *Checksum) {
+	if c == nil {
+		return
+	}
+	switch c.Store {
+	case "", ChecksumSidecar:
+	case ChecksumMetadata:
+		if storageType != "" && storageType != "s3" && storageType != "minio" {
+			v.add(path+".store", "the checksums can only be stored as metadata in s3 and minio storage providers")
+		}
+	default:
+		v.add(path+".store", "unknown store '"+c.Store+"', valid values are: metadata, sidecar")
+	}
+}
+
+// validateRetry checks the values of a retry policy
+func validateRetry(v *validator, path string, retry *RetryPolicy) {
+	if retry.Attempts < 0 {
+		v.add(path+".attempts", "the attempts can't be negative")
+	}
+	if retry.InitialBackoff < 0 {
+		v.add(path+".initial_backoff", "the backoff can't be negative")
+	}
+	if retry.MaxBackoff < 0 {
+		v.add(path+".max_backoff", "the backoff can't be negative")
+	}
+	if retry.Multiplier != 0 && retry.Multiplier < 1 {
+		v.add(path+".multiplier", "the multiplier must be greater than or equal to 1")
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		v.add(path+".jitter", "the jitter must be between 0 and 1")
+	}
+	for i, class := range retry.RetryOn {
+		valid := false
+		for _, retryClass := range RetryClasses {
+			if class == retryClass {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			v.add(path+".retry_on["+strconv.Itoa(i)+"]", "unknown error class '"+class+"', valid classes are: "+strings.Join(RetryClasses, ", "))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	invalidConfig := `{
+		"storages": {
+			"minio": [
+				{
+					"name": "minio",
+					"auth": {
+						"access_key": "user",
+						"secret_key": "pass"
+					}
+				}
+			],
+			"onedata": [
+				{
+					"name": "minio",
+					"auth": {
+						"endpoint": "oneprovider.example",
+						"token": "token",
+						"space": "space"
+					}
+				}
+			],
+			"s3": [
+				{
+					"auth": {
+						"access_key": "key"
+					}
+				}
+			]
+		},
+		"output": [
+			{
+				"storage_name": "minio",
+				"path": "/"
+			},
+			{
+				"storage_name": "minio",
+				"path": "bucket/{event_time:2006}/{key}",
+				"mirror": true
+			},
+			{
+				"storage_name": "undefined",
+				"path": "bucket",
+				"regex": ["ok", "(invalid"],
+				"timeout": -1
+			}
+		],
+		"concurrency": -2
+	}`
+
+	expected := []Problem{
+		{Path: "storages.s3[0].name", Message: "the name is required"},
+		{Path: "storages.s3[0].auth", Message: "both access_key and secret_key must be set (or none to use the default credentials)"},
+		{Path: "storages.minio[0].auth.endpoint", Message: "the endpoint is required"},
+		{Path: "storages.onedata[0].name", Message: "the name 'minio' is already used by a minio storage provider"},
+		{Path: "output[0].path", Message: "the path must start with the bucket name"},
+		{Path: "output[1].mirror", Message: "outputs with the {event_time} variable in their path can't be mirrored"},
+		{Path: "output[2].storage_name", Message: "undefined storage provider 'undefined'"},
+		{Path: "output[2].timeout", Message: "the timeout can't be negative"},
+		{Path: "output[2].regex[1]", Message: "invalid regex '(invalid': error parsing regexp: missing closing ): `(invalid`"},
+		{Path: "concurrency", Message: "the concurrency can't be negative"},
+	}
+
+	_, err := ReadConfig(strings.NewReader(invalidConfig))
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Error validating config: %v", err)
+	}
+	if !reflect.DeepEqual(validationErr.Problems, expected) {
+		t.Errorf("Error reporting config problems:\n%v", validationErr)
+	}
+}
+
+func TestValidateEmptyOutputs(t *testing.T) {
+	_, err := ReadConfig(strings.NewReader(`{"storages": {}}`))
+	if err == nil || !strings.Contains(err.Error(), "output: at least one output is required") {
+		t.Error("Error reporting missing outputs")
+	}
+}
+
+func TestValidateRetryAndDeadLetter(t *testing.T) {
+	invalidConfig := `{
+		"storages": {
+			"s3": [
+				{
+					"name": "s3",
+					"retry": {
+						"attempts": -1,
+						"multiplier": 0.5,
+						"jitter": 2,
+						"retry_on": ["network", "disk"]
+					}
+				}
+			]
+		},
+		"output": [
+			{
+				"storage_name": "s3",
+				"path": "bucket"
+			}
+		],
+		"dead_letter": {
+			"storage_name": "s3"
+		}
+	}`
+
+	expected := []Problem{
+		{Path: "storages.s3[0].retry.attempts", Message: "the attempts can't be negative"},
+		{Path: "storages.s3[0].retry.multiplier", Message: "the multiplier must be greater than or equal to 1"},
+		{Path: "storages.s3[0].retry.jitter", Message: "the jitter must be between 0 and 1"},
+		{Path: "storages.s3[0].retry.retry_on[1]", Message: "unknown error class 'disk', valid classes are: network, timeout, throttling, server"},
+		{Path: "dead_letter.path", Message: "the path must start with the bucket name"},
+	}
+
+	_, err := ReadConfig(strings.NewReader(invalidConfig))
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Error validating config: %v", err)
+	}
+	if !reflect.DeepEqual(validationErr.Problems, expected) {
+		t.Errorf("Error reporting config problems:\n%v", validationErr)
+	}
+}
+
+func TestValidateSourceAction(t *testing.T) {
+	tests := map[string]string{
+		`{"action": "archive"}`:                      "source_action.action: unknown action 'archive', valid actions are: delete, move",
+		`{"action": "move"}`:                         "source_action.path: the archive path is required",
+		`{"action": "move", "path": "bucket/{day}"}`: "source_action.path: unknown variable 'day' in path template",
+		`{"action": "delete", "path": "bucket"}`:     "source_action.path: the path is only valid for the move action",
+	}
+
+	for sourceAction, expected := range tests {
+		config := `{
+			"storages": {"minio": [{"name": "minio"}]},
+			"output": [{"storage_name": "minio", "path": "bucket"}],
+			"source_action": ` + sourceAction + `
+		}`
+		_, err := ReadConfig(strings.NewReader(config))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error reporting invalid source action %s: %v", sourceAction, err)
+		}
+	}
+
+	config := `{
+		"storages": {"minio": [{"name": "minio"}]},
+		"output": [{"storage_name": "minio", "path": "bucket"}, {"storage_name": "minio", "path": "mirror", "mirror": true}],
+		"source_action": {"action": "delete"}
+	}`
+	_, err := ReadConfig(strings.NewReader(config))
+	if err == nil || !strings.Contains(err.Error(), "source_action: the source files can't be deleted or moved when output[1] is mirrored") {
+		t.Errorf("Error reporting source action with mirrored outputs: %v", err)
+	}
+
+	config = `{
+		"storages": {"minio": [{"name": "minio", "auth": {"endpoint": "http://minio:9000", "access_key": "key", "secret_key": "secret"}}]},
+		"output": [{"storage_name": "minio", "path": "bucket"}],
+		"source_action": {"action": "move", "path": "bucket/archive/{key}"}
+	}`
+	cfg, err := ReadConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst, _ := cfg.SourceAction.ArchivePath(&PathVars{Key: "a/b.txt"}); dst != "bucket/archive/a/b.txt" {
+		t.Errorf("Error loading source action: got '%s'", dst)
+	}
+}
+
+func TestValidateMultipart(t *testing.T) {
+	tests := map[string]string{
+		`"minio": [{"name": "minio", "multipart": {"part_size": 1}}]`:   "storages.minio[0].multipart.part_size: the part size must be between 5 and 5120 MiB",
+		`"s3": [{"name": "s3", "multipart": {"concurrency": -1}}]`:      "storages.s3[0].multipart.concurrency: the concurrency can't be negative",
+		`"local": [{"name": "local", "multipart": {"concurrency": 2}}]`: "storages.local[0].multipart: the multipart settings are only valid for S3 compatible providers",
+	}
+
+	for storages, expected := range tests {
+		config := `{
+			"storages": {` + storages + `},
+			"output": [{"storage_name": "out", "path": "bucket"}]
+		}`
+		_, err := ReadConfig(strings.NewReader(config))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error reporting invalid multipart settings %s: %v", storages, err)
+		}
+	}
+}
+
+func TestValidateChecksum(t *testing.T) {
+	tests := map[string]string{
+		`{"store": "file"}`:     "output[0].checksum.store: unknown store 'file', valid values are: metadata, sidecar",
+		`{"store": "metadata"}`: "output[0].checksum.store: the checksums can only be stored as metadata in s3 and minio storage providers",
+	}
+
+	for checksum, expected := range tests {
+		config := `{
+			"storages": {"local": [{"name": "local", "directory": "/data"}]},
+			"output": [{"storage_name": "local", "path": "out", "checksum": ` + checksum + `}]
+		}`
+		_, err := ReadConfig(strings.NewReader(config))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error reporting invalid checksum options %s: %v", checksum, err)
+		}
+	}
+
+	config := `{
+		"storages": {"local": [{"name": "local", "directory": "/data"}]},
+		"output": [{"storage_name": "local", "path": "out", "checksum": {"verify": true, "store": "sidecar"}}]
+	}`
+	if _, err := ReadConfig(strings.NewReader(config)); err != nil {
+		t.Errorf("Error reading valid checksum options: %v", err)
+	}
+}
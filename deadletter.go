@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/router"
+)
+
+// deadLetterTimeLayout is used to prefix the dead-letter files, so they are sorted by time
+const deadLetterTimeLayout = "20060102T150405.000000000Z"
+
+// deadLetter struct to represent the documents stored in the dead-letter
+// destination, with the original event and the details of the errors
+type deadLetter struct {
+	Event  json.RawMessage `json:"event"`
+	Record router.Result   `json:"record"`
+	Time   string          `json:"time"`
+}
+
+// writeDeadLetter stores the original event and the result of a failed record
+// in the dead-letter destination, so it can be replayed later.
+// Returns the path of the stored file
+func writeDeadLetter(ctx context.Context, cfg *config.Config, rawEvent []byte, record router.Result, providerClients *clientCache) (string, error) {
+	provName := cfg.DeadLetter.StorageProviderName
+	client, err := providerClients.Get(provName)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	doc, err := json.MarshalIndent(&deadLetter{
+		Event:  json.RawMessage(rawEvent),
+		Record: record,
+		Time:   now.Format(time.RFC3339Nano),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	fileName := now.Format(deadLetterTimeLayout) + "-" + strings.Replace(record.EventKey, "/", "_", -1) + ".json"
+	path := strings.TrimRight(cfg.DeadLetter.Path, "/") + "/" + fileName
+	policy := cfg.StorageProviders[provName].Retry
+	err = clients.Retry(ctx, &policy, func(attempt int) error {
+		return client.Put(ctx, bytes.NewReader(doc), path, &clients.Metadata{ContentType: "application/json"})
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/events"
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/config"
+	"handler/function/events"
+	"handler/function/router"
+)
+
+// isDryRun returns true if the dry-run mode is enabled for the request, with
+// the "X-Dry-Run" header or for all requests with the "DRY_RUN" environment
+// variable
+func isDryRun(r *http.Request) bool {
+	for _, value := range []string{r.Header.Get("X-Dry-Run"), os.Getenv("DRY_RUN")} {
+		if dryRun, err := strconv.ParseBool(value); err == nil && dryRun {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun returns the JSON document with the routing decisions for an event,
+// without downloading or uploading any file
+func DryRun(cfg *config.Config, rawEvent []byte) ([]byte, error) {
+	eventList, err := events.ReadEvent(string(rawEvent))
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(planEvents(context.Background(), cfg, eventList), "", "  ")
+}
+
+// planEvents evaluates the outputs for every record of the event, returning
+// the destinations where the files would be written or deleted
+func planEvents(ctx context.Context, cfg *config.Config, eventList []*events.Event) *response {
+	rt := router.New(cfg)
+	res := &response{
+		DryRun:  true,
+		Records: make([]router.Result, 0, len(eventList)),
+	}
+	for _, event := range eventList {
+		res.Records = append(res.Records, rt.Route(ctx, event).Result())
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/router"
+)
+
+func TestHandleDryRun(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(filepath.Join(dir, "input", "videos", "video.avi"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Records":[{"eventSource":"local", "path":"videos/video.avi"}]}`))
+	r.Header.Set("X-Dry-Run", "true")
+	Handle(w, r)
+
+	var res response
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	expected := response{
+		DryRun: true,
+		Records: []router.Result{
+			{
+				EventKey: "videos/video.avi",
+				Source:   "local",
+				Status:   router.StatusRouted,
+				Outputs: []router.OutputResult{
+					{StorageName: "output", Path: "videos/videos/video.avi", Status: router.StatusPlanned},
+					{StorageName: "input", Path: "copies/video.avi", Status: router.StatusPlanned},
+				},
+			},
+		},
+	}
+	if w.Code != http.StatusOK || !reflect.DeepEqual(res, expected) {
+		t.Errorf("Error planning event: %d %+v", w.Code, res)
+	}
+	// Nothing is transferred
+	for _, file := range []string{"output/videos/videos/video.avi", "input/copies/video.avi"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(err) {
+			t.Errorf("Error in dry-run mode, file '%s' was uploaded", file)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	cfg, _, err := cachedConfig.load(filepath.Join(dir, "secrets", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := DryRun(cfg, []byte(`{
+		"Records":[
+			{"eventSource":"local", "eventName":"ObjectRemoved", "path":"videos/video.avi"},
+			{"eventSource":"local", "path":"videos/video.mp4"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res response
+	if err := json.Unmarshal(out, &res); err != nil {
+		t.Fatal(err)
+	}
+	// Removed files are only deleted from the mirrored outputs
+	if len(res.Records) != 2 || len(res.Records[0].Outputs) != 1 || res.Records[0].Outputs[0].Method != router.MethodDelete {
+		t.Errorf("Error planning removed file: %s", out)
+	}
+	if res.Records[1].Status != router.StatusUnmatched {
+		t.Errorf("Error planning unmatched file: %s", out)
+	}
+
+	if _, err := DryRun(cfg, []byte("{}")); err == nil {
+		t.Error("Error reporting invalid event")
+	}
+}
+
+func TestHandleGCSEvent(t *testing.T) {
+	_, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	event := `{
+		"specversion":"1.0",
+		"type":"google.cloud.storage.object.v1.finalized",
+		"source":"//storage.googleapis.com/projects/_/buckets/input",
+		"time":"2019-02-23T11:40:46.473Z",
+		"data":{"bucket":"input","name":"videos/video.avi"}
+	}`
+
+	// The files of GCS events can't be downloaded
+	code, res := callHandle(t, event)
+	if code != http.StatusBadRequest || !strings.Contains(res.Error, "only be routed in dry-run mode") || len(res.Records) != 0 {
+		t.Errorf("Error rejecting GCS event: %d %+v", code, res)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(event))
+	r.Header.Set("X-Dry-Run", "true")
+	Handle(w, r)
+	var planned response
+	if err := json.Unmarshal(w.Body.Bytes(), &planned); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(planned.Records) != 1 || planned.Records[0].Status != router.StatusRouted {
+		t.Errorf("Error planning GCS event: %d %+v", w.Code, planned)
+	}
+}
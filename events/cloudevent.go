@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
+)
+
+// cloudEventSpecVersion is the supported version of the CloudEvents spec
+const cloudEventSpecVersion = "1.0"
+
+// cloudEvent struct to represent the attributes and data of a CloudEvent
+type cloudEvent struct {
+	SpecVersion string          `json:"specversion"`
+	Type        string          `json:"type"`
+	Source      string          `json:"source"`
+	Subject     string          `json:"subject"`
+	Time        string          `json:"time"`
+	Data        json.RawMessage `json:"data"`
+	DataBase64  string          `json:"data_base64"`
+}
+
+// cloudEventSources maps the prefixes of the CloudEvent types to the source
+// of the events
+var cloudEventSources = []struct {
+	prefix string
+	source string
+}{
+	{"com.amazonaws.s3.", "s3"},
+	{"io.minio.", "minio"},
+	// Argo Events sets the type of the event source as CloudEvent type
+	{"minio", "minio"},
+	{"google.cloud.storage.object.v1.", "gcs"},
+	{"com.google.cloud.storage.object.", "gcs"},
+}
+
+// ReadHTTPEvent function to process the events received in HTTP requests.
+// CloudEvents in binary mode are read from the "ce-" headers, with the
+// body as data. Otherwise the body is processed by ReadEvent
+func ReadHTTPEvent(header http.Header, body []byte) ([]*Event, error) {
+	specVersion := header.Get("Ce-Specversion")
+	if specVersion == "" {
+		return ReadEvent(string(body))
+	}
+	return readCloudEvent(&cloudEvent{
+		SpecVersion: specVersion,
+		Type:        header.Get("Ce-Type"),
+		Source:      header.Get("Ce-Source"),
+		Subject:     header.Get("Ce-Subject"),
+		Time:        header.Get("Ce-Time"),
+		Data:        body,
+	})
+}
+
+// readStructuredCloudEvent function to process a CloudEvent in structured
+// mode, with the attributes and data in the JSON document
+func readStructuredCloudEvent(rawEvent []byte) ([]*Event, error) {
+	ce := &cloudEvent{}
+	if err := json.Unmarshal(rawEvent, ce); err != nil {
+		return nil, errInvalidEvent
+	}
+	if ce.DataBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(ce.DataBase64)
+		if err != nil {
+			return nil, errInvalidEvent
+		}
+		ce.Data = data
+	}
+	return readCloudEvent(ce)
+}
+
+// readCloudEvent function to process the data of a CloudEvent, depending
+// on the storage provider of its type
+func readCloudEvent(ce *cloudEvent) ([]*Event, error) {
+	if ce.SpecVersion != cloudEventSpecVersion {
+		return nil, errors.New("Unsupported CloudEvents spec version '" + ce.SpecVersion + "'")
+	}
+
+	var source string
+	for _, s := range cloudEventSources {
+		if strings.HasPrefix(ce.Type, s.prefix) {
+			source = s.source
+			break
+		}
+	}
+	if source == "" {
+		return nil, errors.New("Unsupported CloudEvent type '" + ce.Type + "'")
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(ce.Data, &data); err != nil {
+		return nil, errInvalidEvent
+	}
+
+	if source == "gcs" {
+		event, err := readGCSObject(ce, data)
+		if err != nil {
+			return nil, err
+		}
+		return []*Event{event}, nil
+	}
+
+	// MinIO and S3 records, as a notification ("Records"), a list of records
+	// (Argo Events "notification") or a single record
+	var records []interface{}
+	if list, ok := data["Records"].([]interface{}); ok {
+		records = list
+	} else if list, ok := data["notification"].([]interface{}); ok {
+		records = list
+	} else if _, ok := data["s3"]; ok {
+		records = []interface{}{data}
+	}
+	if len(records) == 0 {
+		return nil, errInvalidEvent
+	}
+
+	var events []*Event
+	for _, rawRecord := range records {
+		record, ok := rawRecord.(map[string]interface{})
+		if !ok {
+			return nil, errInvalidEvent
+		}
+		// Use the attributes of the CloudEvent if the record doesn't
+		// include the event time or name
+		if _, ok := record["eventTime"].(string); !ok {
+			record["eventTime"] = cloudEventTime(ce)
+		}
+		if _, ok := record["eventName"].(string); !ok {
+			record["eventName"] = cloudEventType(ce)
+		}
+		event, err := readS3Object(record, source)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// readGCSObject function to process the data of Google Cloud Storage events.
+// Only the finalize (created) and delete events are supported
+func readGCSObject(ce *cloudEvent, data map[string]interface{}) (*Event, error) {
+	eventType := cloudEventType(ce)
+	if eventType == "" {
+		return nil, errors.New("Unsupported CloudEvent type '" + ce.Type + "'")
+	}
+
+	bucket, okBucket := data["bucket"].(string)
+	key, okKey := data["name"].(string)
+	if !okBucket || !okKey || key == "" {
+		return nil, errInvalidEvent
+	}
+
+	event := &Event{
+		Path:        bucket + "/" + key,
+		ObjectKey:   key,
+		EventTime:   cloudEventTime(ce),
+		EventSource: "gcs",
+		Bucket:      bucket,
+		EventType:   eventType,
+	}
+	// The size is encoded as a string in the object resource
+	if size, ok := data["size"].(string); ok {
+		event.Size, _ = strconv.ParseInt(size, 10, 64)
+	}
+	event.ETag, _ = data["etag"].(string)
+	event.ContentType, _ = data["contentType"].(string)
+	if metadata, ok := data["metadata"].(map[string]interface{}); ok {
+		event.Metadata = make(map[string]string)
+		for key, value := range metadata {
+			event.Metadata[config.MetadataKey(key)], _ = value.(string)
+		}
+	}
+	return event, nil
+}
+
+// cloudEventType function to get the type of an event from the CloudEvent
+// type (e.g. "com.amazonaws.s3.ObjectRemoved:Delete" or
+// "google.cloud.storage.object.v1.finalized"). Returns an empty string if
+// the type isn't a creation or a deletion
+func cloudEventType(ce *cloudEvent) string {
+	t := strings.ToLower(ce.Type)
+	switch {
+	case strings.Contains(t, "objectremoved"), strings.HasSuffix(t, ".deleted"), strings.HasSuffix(t, ".delete"):
+		return ObjectRemoved
+	case strings.Contains(t, "objectcreated"), strings.HasSuffix(t, ".finalized"), strings.HasSuffix(t, ".finalize"):
+		return ObjectCreated
+	}
+	// Argo Events MinIO events only set the event name in the records
+	if ce.Type == "minio" {
+		return ObjectCreated
+	}
+	return ""
+}
+
+// cloudEventTime function to get the time of a CloudEvent, the current
+// time if it's not set
+func cloudEventTime(ce *cloudEvent) string {
+	if ce.Time == "" {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return ce.Time
+}
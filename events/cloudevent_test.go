@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestReadStructuredCloudEvent(t *testing.T) {
+	s3Event := `{
+		"specversion":"1.0",
+		"id":"5c1b1e5a-1c5a-4a5e-9f0e-3a2f1e4f7d6b",
+		"type":"com.amazonaws.s3.ObjectCreated:Put",
+		"source":"arn:aws:s3:::images",
+		"subject":"nature-wallpaper-229.jpg",
+		"time":"2019-02-23T11:40:46.473Z",
+		"datacontenttype":"application/json",
+		"data":{
+			"eventVersion":"2.1",
+			"eventSource":"aws:s3",
+			"awsRegion":"us-east-1",
+			"eventTime":"2019-02-23T11:40:46.470Z",
+			"eventName":"ObjectCreated:Put",
+			"s3":{
+				"bucket":{
+					"name":"images"
+				},
+				"object":{
+					"key":"nature-wallpaper-229.jpg",
+					"size":1019645
+				}
+			}
+		}
+	}`
+	expected := Event{
+		Path:        "images/nature-wallpaper-229.jpg",
+		ObjectKey:   "nature-wallpaper-229.jpg",
+		EventTime:   "2019-02-23T11:40:46.470Z",
+		EventSource: "s3",
+		Bucket:      "images",
+		EventType:   ObjectCreated,
+		Size:        1019645,
+	}
+	if events, err := ReadEvent(s3Event); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
+		t.Errorf("Error reading S3 CloudEvent. Expected: %+v. Received: %+v, %v", expected, events, err)
+	}
+
+	// Argo Events MinIO event, with the records in "notification"
+	minioEvent := `{
+		"specversion":"1.0",
+		"type":"minio",
+		"source":"minio-eventsource",
+		"subject":"example",
+		"time":"2019-02-23T11:40:47Z",
+		"data":{
+			"notification":[
+				{
+					"eventName":"s3:ObjectRemoved:Delete",
+					"eventTime":"2019-02-23T11:40:46.473Z",
+					"s3":{
+						"bucket":{
+							"name":"input"
+						},
+						"object":{
+							"key":"videos%2Fvideo-1.avi"
+						}
+					}
+				}
+			]
+		}
+	}`
+	expected = Event{
+		Path:        "input/videos/video-1.avi",
+		ObjectKey:   "videos/video-1.avi",
+		EventTime:   "2019-02-23T11:40:46.473Z",
+		EventSource: "minio",
+		Bucket:      "input",
+		EventType:   ObjectRemoved,
+	}
+	if events, err := ReadEvent(minioEvent); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
+		t.Errorf("Error reading MinIO CloudEvent. Expected: %+v. Received: %+v, %v", expected, events, err)
+	}
+
+	// Google Cloud Storage event with base64 data
+	gcsEvent := `{
+		"specversion":"1.0",
+		"type":"google.cloud.storage.object.v1.finalized",
+		"source":"//storage.googleapis.com/projects/_/buckets/images",
+		"subject":"objects/photos/photo.jpg",
+		"time":"2019-02-23T11:40:46.473Z",
+		"data_base64":"eyJidWNrZXQiOiJpbWFnZXMiLCJuYW1lIjoicGhvdG9zL3Bob3RvLmpwZyIsInNpemUiOiIyMDQ4IiwiY29udGVudFR5cGUiOiJpbWFnZS9qcGVnIiwibWV0YWRhdGEiOnsiUXVhbGl0eSI6InJhdyJ9fQ=="
+	}`
+	expected = Event{
+		Path:        "images/photos/photo.jpg",
+		ObjectKey:   "photos/photo.jpg",
+		EventTime:   "2019-02-23T11:40:46.473Z",
+		EventSource: "gcs",
+		Bucket:      "images",
+		EventType:   ObjectCreated,
+		Size:        2048,
+		ContentType: "image/jpeg",
+		Metadata:    map[string]string{"quality": "raw"},
+	}
+	if events, err := ReadEvent(gcsEvent); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
+		t.Errorf("Error reading GCS CloudEvent. Expected: %+v. Received: %+v, %v", expected, events, err)
+	}
+	if !expected.PlanOnly() {
+		t.Error("Error reporting GCS events as plan-only")
+	}
+}
+
+func TestReadBinaryCloudEvent(t *testing.T) {
+	header := http.Header{}
+	header.Set("Ce-Specversion", "1.0")
+	header.Set("Ce-Id", "1234")
+	header.Set("Ce-Type", "com.amazonaws.s3.ObjectRemoved:Delete")
+	header.Set("Ce-Source", "arn:aws:s3:::images")
+	header.Set("Ce-Time", "2019-02-23T11:40:46.473Z")
+	header.Set("Content-Type", "application/json")
+	body := `{
+		"s3":{
+			"bucket":{
+				"name":"images"
+			},
+			"object":{
+				"key":"photo.jpg"
+			}
+		}
+	}`
+	expected := Event{
+		Path:        "images/photo.jpg",
+		ObjectKey:   "photo.jpg",
+		EventTime:   "2019-02-23T11:40:46.473Z",
+		EventSource: "s3",
+		Bucket:      "images",
+		EventType:   ObjectRemoved,
+	}
+	if events, err := ReadHTTPEvent(header, []byte(body)); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
+		t.Errorf("Error reading binary CloudEvent. Expected: %+v. Received: %+v, %v", expected, events, err)
+	}
+
+	// Requests without CloudEvents headers are processed by ReadEvent
+	localEvent := `{"Records":[{"eventSource":"local","path":"file.txt"}]}`
+	if events, err := ReadHTTPEvent(http.Header{}, []byte(localEvent)); err != nil || len(events) != 1 || events[0].ObjectKey != "file.txt" {
+		t.Errorf("Error reading event without CloudEvents headers. Received: %+v, %v", events, err)
+	}
+}
+
+func TestReadInvalidCloudEvents(t *testing.T) {
+	tests := []string{
+		// Unsupported version
+		`{"specversion":"0.3","type":"com.amazonaws.s3.ObjectCreated:Put","data":{}}`,
+		// Unsupported type
+		`{"specversion":"1.0","type":"com.example.object.created","data":{}}`,
+		`{"specversion":"1.0","type":"google.cloud.storage.object.v1.metadataUpdated","data":{"bucket":"images","name":"photo.jpg"}}`,
+		// Invalid data
+		`{"specversion":"1.0","type":"com.amazonaws.s3.ObjectCreated:Put","data":"photo.jpg"}`,
+		`{"specversion":"1.0","type":"com.amazonaws.s3.ObjectCreated:Put","data":{"Records":[]}}`,
+		`{"specversion":"1.0","type":"google.cloud.storage.object.v1.finalized","data":{"bucket":"images"}}`,
+		`{"specversion":"1.0","type":"google.cloud.storage.object.v1.finalized","data_base64":"!"}`,
+	}
+
+	for _, test := range tests {
+		if _, err := ReadEvent(test); err == nil {
+			t.Errorf("Error reading invalid CloudEvent: %s", test)
+		}
+	}
+}
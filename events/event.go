@@ -23,6 +23,11 @@ import (
 	"encoding/json"
 	"errors"
 	"net/url"
+	"strings"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/config"
 )
 
 // Event struct used to load events
@@ -31,12 +36,61 @@ type Event struct {
 	ObjectKey   string `json:"objectKey"`
 	EventTime   string `json:"eventTime"`
 	EventSource string `json:"eventSource"`
+	// Bucket name (or space name in Onedata events)
+	Bucket string `json:"bucket"`
+	// Type of the event (ObjectCreated or ObjectRemoved)
+	EventType string `json:"eventType"`
+	// Attributes of the file included in the event. The size is 0 and the
+	// content type empty if they are unknown, as well as nil maps
+	Size        int64  `json:"size"`
+	ETag        string `json:"eTag"`
+	ContentType string `json:"contentType"`
+	// User metadata with the normalized keys (see config.MetadataKey)
+	Metadata map[string]string `json:"metadata"`
+	Tags     map[string]string `json:"tags"`
 }
 
+// Event types
+const (
+	ObjectCreated = "ObjectCreated"
+	ObjectRemoved = "ObjectRemoved"
+)
+
 var errInvalidEvent = errors.New("Invalid event")
 
-// ReadEvent function to process raw events
-func ReadEvent(rawEvent string) (*Event, error) {
+// eventTimeLayouts are the formats of the event times sent by the providers
+// (OneTrigger events don't include the time zone, UTC is assumed)
+var eventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+// Time returns the parsed event time
+func (e *Event) Time() (time.Time, error) {
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, e.EventTime); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Invalid event time '" + e.EventTime + "'")
+}
+
+// planOnlySources are the event sources without a storage provider type, so
+// their files can't be downloaded
+var planOnlySources = map[string]bool{
+	"gcs": true,
+}
+
+// PlanOnly returns true if the files of the event can't be downloaded by any
+// storage provider, so it can only be routed in dry-run mode
+func (e *Event) PlanOnly() bool {
+	return planOnlySources[e.EventSource]
+}
+
+// ReadEvent function to process raw events (including CloudEvents in
+// structured mode). Bucket notifications can contain several records, so an
+// event is returned for each one of them
+func ReadEvent(rawEvent string) ([]*Event, error) {
 	var eventMap map[string]interface{}
 
 	err := json.Unmarshal([]byte(rawEvent), &eventMap)
@@ -44,8 +98,13 @@ func ReadEvent(rawEvent string) (*Event, error) {
 		return nil, errInvalidEvent
 	}
 
+	// CloudEvents in structured mode
+	if _, ok := eventMap["specversion"]; ok {
+		return readStructuredCloudEvent([]byte(rawEvent))
+	}
+
 	records, ok := eventMap["Records"].([]interface{})
-	if !ok {
+	if !ok || len(records) == 0 {
 		return nil, errInvalidEvent
 	}
 
@@ -54,39 +113,126 @@ func ReadEvent(rawEvent string) (*Event, error) {
 		return nil, errInvalidEvent
 	}
 
-	// OneTrigger events
+	// OneTrigger events (only contain one record)
 	if record0["eventSource"] == "OneTrigger" {
+		path, okPath := eventMap["Key"].(string)
+		objectKey, okKey := record0["objectKey"].(string)
+		eventTime, okTime := record0["eventTime"].(string)
+		if !okPath || !okKey || !okTime {
+			return nil, errInvalidEvent
+		}
 		event := &Event{
-			Path:        eventMap["Key"].(string),
-			ObjectKey:   record0["objectKey"].(string),
-			EventTime:   record0["eventTime"].(string),
+			Path:        path,
+			ObjectKey:   objectKey,
+			EventTime:   eventTime,
 			EventSource: "onedata",
+			Bucket:      strings.SplitN(strings.TrimLeft(path, "/"), "/", 2)[0],
+			EventType:   ObjectCreated,
+		}
+		return []*Event{event}, nil
+	}
+
+	// MinIO, S3 and local events
+	var events []*Event
+	for _, rawRecord := range records {
+		record, ok := rawRecord.(map[string]interface{})
+		if !ok {
+			return nil, errInvalidEvent
 		}
-		return event, nil
+		readRecord := readS3Record
+		if record["eventSource"] == "local" {
+			readRecord = readLocalRecord
+		}
+		event, err := readRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// readEventType function to get the type of an event from its name, with the
+// format "[s3:]ObjectRemoved:Delete"
+func readEventType(record map[string]interface{}) string {
+	if eventName, _ := record["eventName"].(string); strings.Contains(eventName, ObjectRemoved) {
+		return ObjectRemoved
+	}
+	return ObjectCreated
+}
+
+// readLocalRecord function to process a record from local events, with the
+// path of the file relative to the directory of the local storage providers.
+// The event time is optional
+func readLocalRecord(record map[string]interface{}) (*Event, error) {
+	filePath, ok := record["path"].(string)
+	key := strings.TrimLeft(filePath, "/")
+	if !ok || key == "" {
+		return nil, errInvalidEvent
+	}
+
+	eventTime, ok := record["eventTime"].(string)
+	if !ok {
+		eventTime = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	event := &Event{
+		Path:        key,
+		ObjectKey:   key,
+		EventTime:   eventTime,
+		EventSource: "local",
+		EventType:   readEventType(record),
 	}
 
-	// MinIO and S3 events
+	return event, nil
+}
+
+// readS3Record function to process a record from MinIO and S3 events
+func readS3Record(record map[string]interface{}) (*Event, error) {
 	var source string
-	if record0["eventSource"] == "aws:s3" {
+	if record["eventSource"] == "aws:s3" {
 		source = "s3"
-	} else if record0["eventSource"] == "minio:s3" {
+	} else if record["eventSource"] == "minio:s3" {
 		source = "minio"
 	} else {
 		// Return error if "eventSource" has unsopported provider
 		return nil, errInvalidEvent
 	}
+	return readS3Object(record, source)
+}
+
+// readS3Object function to process the object of a MinIO or S3 record
+func readS3Object(record map[string]interface{}, source string) (*Event, error) {
+	s3Info, ok := record["s3"].(map[string]interface{})
+	if !ok {
+		return nil, errInvalidEvent
+	}
 
-	key, ok := record0["s3"].(map[string]interface{})["object"].(map[string]interface{})["key"].(string)
+	object, ok := s3Info["object"].(map[string]interface{})
+	if !ok {
+		return nil, errInvalidEvent
+	}
+	key, ok := object["key"].(string)
 	if !ok {
 		return nil, errInvalidEvent
 	}
 	// Decode url encoded key
-	key, err = url.QueryUnescape(key)
+	key, err := url.QueryUnescape(key)
 	if err != nil {
 		return nil, errInvalidEvent
 	}
 
-	bucket, ok := record0["s3"].(map[string]interface{})["bucket"].(map[string]interface{})["name"].(string)
+	bucketInfo, ok := s3Info["bucket"].(map[string]interface{})
+	if !ok {
+		return nil, errInvalidEvent
+	}
+	bucket, ok := bucketInfo["name"].(string)
+	if !ok {
+		return nil, errInvalidEvent
+	}
+
+	eventTime, ok := record["eventTime"].(string)
 	if !ok {
 		return nil, errInvalidEvent
 	}
@@ -94,9 +240,36 @@ func ReadEvent(rawEvent string) (*Event, error) {
 	event := &Event{
 		Path:        bucket + "/" + key,
 		ObjectKey:   key,
-		EventTime:   record0["eventTime"].(string),
+		EventTime:   eventTime,
 		EventSource: source,
+		Bucket:      bucket,
+		EventType:   readEventType(record),
 	}
+	readObjectAttributes(event, object)
 
 	return event, nil
 }
+
+// readObjectAttributes function to read the size, eTag, content type and
+// user metadata of the object of MinIO and S3 records. Only MinIO includes
+// the content type and user metadata
+func readObjectAttributes(event *Event, object map[string]interface{}) {
+	if size, ok := object["size"].(float64); ok {
+		event.Size = int64(size)
+	}
+	event.ETag, _ = object["eTag"].(string)
+	event.ContentType, _ = object["contentType"].(string)
+	userMetadata, ok := object["userMetadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	event.Metadata = make(map[string]string)
+	for key, rawValue := range userMetadata {
+		value, _ := rawValue.(string)
+		if config.IsMetadataHeader(key) {
+			event.Metadata[config.MetadataKey(key)] = value
+		} else if strings.EqualFold(key, "content-type") && event.ContentType == "" {
+			event.ContentType = value
+		}
+	}
+}
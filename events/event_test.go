@@ -22,6 +22,7 @@ package events
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestReadMinioEvent(t *testing.T) {
@@ -81,13 +82,63 @@ func TestReadMinioEvent(t *testing.T) {
 		ObjectKey:   "nature-wallpaper-229.jpg",
 		EventTime:   "2018-06-29T10:23:44Z",
 		EventSource: "minio",
+		Bucket:      "images",
+		EventType:   ObjectCreated,
+		Size:        1019645,
+		ETag:        "dd20b7e4b74467ff16ce2d901c054419",
+		ContentType: "image/jpeg",
+		Metadata:    map[string]string{},
 	}
 
-	if event, err := ReadEvent(minioEvent); err != nil || !reflect.DeepEqual(*event, expected) {
+	if events, err := ReadEvent(minioEvent); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
 		t.Error("Error loading minio event")
 	}
 }
 
+func TestReadObjectAttributes(t *testing.T) {
+	minioEvent := `{
+		"Records":[
+			{
+				"eventName":"s3:ObjectCreated:Put",
+				"eventSource":"minio:s3",
+				"eventTime":"2019-02-23T11:40:46.473Z",
+				"s3":{
+					"bucket":{
+						"name":"input"
+					},
+					"object":{
+						"key":"video.avi",
+						"size":2048,
+						"eTag":"dd20b7e4b74467ff16ce2d901c054419",
+						"userMetadata":{
+							"content-type":"video/x-msvideo",
+							"X-Amz-Meta-Quality":"raw",
+							"x-amz-meta-camera":"1"
+						}
+					}
+				}
+			}
+		]
+	}`
+
+	expected := Event{
+		Path:        "input/video.avi",
+		ObjectKey:   "video.avi",
+		EventTime:   "2019-02-23T11:40:46.473Z",
+		EventSource: "minio",
+		Bucket:      "input",
+		EventType:   ObjectCreated,
+		Size:        2048,
+		ETag:        "dd20b7e4b74467ff16ce2d901c054419",
+		ContentType: "video/x-msvideo",
+		Metadata:    map[string]string{"quality": "raw", "camera": "1"},
+	}
+
+	if events, err := ReadEvent(minioEvent); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
+		t.Error("Error loading object attributes")
+	}
+}
+
 func TestReadS3Event(t *testing.T) {
 	s3Event := `{
 		"Records":[
@@ -133,9 +184,13 @@ func TestReadS3Event(t *testing.T) {
 		ObjectKey:   "scar-darknet-s3/input/dog.jpg",
 		EventTime:   "2019-02-23T11:40:46.473Z",
 		EventSource: "s3",
+		Bucket:      "scar-darknet-bucket",
+		EventType:   ObjectCreated,
+		Size:        999,
+		ETag:        "XXXXX",
 	}
 
-	if event, err := ReadEvent(s3Event); err != nil || !reflect.DeepEqual(*event, expected) {
+	if events, err := ReadEvent(s3Event); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
 		t.Error("Error loading S3 event")
 	}
 }
@@ -158,13 +213,160 @@ func TestReadOnedataEvent(t *testing.T) {
 		ObjectKey:   "file.txt",
 		EventTime:   "2019-02-07T09:51:04.347823",
 		EventSource: "onedata",
+		Bucket:      "my-onedata-space",
+		EventType:   ObjectCreated,
 	}
 
-	if event, err := ReadEvent(onedataEvent); err != nil || !reflect.DeepEqual(*event, expected) {
+	if events, err := ReadEvent(onedataEvent); err != nil || len(events) != 1 || !reflect.DeepEqual(*events[0], expected) {
 		t.Error("Error loading Onedata event")
 	}
 }
 
+func TestReadMultiRecordEvent(t *testing.T) {
+	multiRecordEvent := `{
+		"Records":[
+			{
+				"eventName":"s3:ObjectCreated:Put",
+				"eventSource":"minio:s3",
+				"eventTime":"2019-02-23T11:40:46.473Z",
+				"s3":{
+					"bucket":{
+						"name":"input"
+					},
+					"object":{
+						"key":"videos%2Fvideo-1.avi"
+					}
+				}
+			},
+			{
+				"eventName":"s3:ObjectCreated:Put",
+				"eventSource":"minio:s3",
+				"eventTime":"2019-02-23T11:40:47.473Z",
+				"s3":{
+					"bucket":{
+						"name":"input"
+					},
+					"object":{
+						"key":"audio/audio-1.wav"
+					}
+				}
+			}
+		]
+	}`
+
+	expected := []Event{
+		Event{
+			Path:        "input/videos/video-1.avi",
+			ObjectKey:   "videos/video-1.avi",
+			EventTime:   "2019-02-23T11:40:46.473Z",
+			EventSource: "minio",
+			Bucket:      "input",
+			EventType:   ObjectCreated,
+		},
+		Event{
+			Path:        "input/audio/audio-1.wav",
+			ObjectKey:   "audio/audio-1.wav",
+			EventTime:   "2019-02-23T11:40:47.473Z",
+			EventSource: "minio",
+			Bucket:      "input",
+			EventType:   ObjectCreated,
+		},
+	}
+
+	events, err := ReadEvent(multiRecordEvent)
+	if err != nil || len(events) != len(expected) {
+		t.Fatal("Error loading multi-record event")
+	}
+	for i, event := range events {
+		if !reflect.DeepEqual(*event, expected[i]) {
+			t.Errorf("Error loading record %d of multi-record event", i)
+		}
+	}
+}
+
+func TestReadRemovedEvent(t *testing.T) {
+	for _, eventName := range []string{"s3:ObjectRemoved:Delete", "ObjectRemoved:DeleteMarkerCreated"} {
+		removedEvent := `{
+			"Records":[
+				{
+					"eventName":"` + eventName + `",
+					"eventSource":"minio:s3",
+					"eventTime":"2019-02-23T11:40:46.473Z",
+					"s3":{
+						"bucket":{
+							"name":"input"
+						},
+						"object":{
+							"key":"file.txt"
+						}
+					}
+				}
+			]
+		}`
+		events, err := ReadEvent(removedEvent)
+		if err != nil || len(events) != 1 || events[0].EventType != ObjectRemoved {
+			t.Errorf("Error loading '%s' event", eventName)
+		}
+	}
+}
+
+func TestReadLocalEvent(t *testing.T) {
+	localEvent := `{
+		"Records":[
+			{
+				"eventSource":"local",
+				"eventTime":"2019-02-23T11:40:46.473Z",
+				"path":"/videos/video.avi"
+			},
+			{
+				"eventSource":"local",
+				"eventName":"ObjectRemoved",
+				"path":"file.txt"
+			}
+		]
+	}`
+
+	events, err := ReadEvent(localEvent)
+	if err != nil || len(events) != 2 {
+		t.Fatal("Error loading local event")
+	}
+
+	expected := &Event{
+		Path:        "videos/video.avi",
+		ObjectKey:   "videos/video.avi",
+		EventTime:   "2019-02-23T11:40:46.473Z",
+		EventSource: "local",
+		EventType:   ObjectCreated,
+	}
+	if !reflect.DeepEqual(events[0], expected) {
+		t.Error("Error loading local event")
+	}
+	if events[1].ObjectKey != "file.txt" || events[1].EventType != ObjectRemoved {
+		t.Error("Error loading local event")
+	}
+	if _, err := events[1].Time(); err != nil {
+		t.Error("Error setting the time of local events")
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	tests := map[string]time.Time{
+		"2019-02-23T11:40:46.473Z":   time.Date(2019, 2, 23, 11, 40, 46, 473000000, time.UTC),
+		"2018-06-29T10:23:44Z":       time.Date(2018, 6, 29, 10, 23, 44, 0, time.UTC),
+		"2019-02-07T09:51:04.347823": time.Date(2019, 2, 7, 9, 51, 4, 347823000, time.UTC),
+	}
+	for eventTime, expected := range tests {
+		e := &Event{EventTime: eventTime}
+		if parsed, err := e.Time(); err != nil || !parsed.Equal(expected) {
+			t.Errorf("Error parsing event time '%s'", eventTime)
+		}
+	}
+
+	if _, err := (&Event{EventTime: "yesterday"}).Time(); err == nil {
+		t.Error("Error parsing invalid event time")
+	}
+}
+
 func TestReadInvalidEvents(t *testing.T) {
 	tests := []string{
 		"",
@@ -180,6 +382,37 @@ func TestReadInvalidEvents(t *testing.T) {
 				"s3": [""]
 			]
 		}`,
+		`{
+			"Records":[]
+		}`,
+		`{
+			"Records":[
+				{
+					"eventSource":"local",
+					"path":"/"
+				}
+			]
+		}`,
+		`{
+			"Records":[
+				{
+					"eventSource":"minio:s3",
+					"eventTime":"2019-02-23T11:40:46.473Z",
+					"s3":{
+						"bucket":{
+							"name":"input"
+						},
+						"object":{
+							"key":"file.txt"
+						}
+					}
+				},
+				{
+					"eventSource":"minio:s3",
+					"s3":{}
+				}
+			]
+		}`,
 	}
 
 	for _, test := range tests {
@@ -20,140 +20,184 @@
 package function
 
 import (
+	"errors"
 	"io/ioutil"
-	"log"
+	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	//"github.com/grycap/multi-out-faas/clients"
 	//"github.com/grycap/multi-out-faas/config"
 	//"github.com/grycap/multi-out-faas/events"
-	"handler/function/clients"
+	//"github.com/grycap/multi-out-faas/logging"
+	//"github.com/grycap/multi-out-faas/metrics"
+	//"github.com/grycap/multi-out-faas/router"
 	"handler/function/config"
 	"handler/function/events"
+	"handler/function/logging"
+	"handler/function/metrics"
+	"handler/function/router"
 )
 
-// Handle a serverless request
-func Handle(req []byte) string {
+// secretsDir is the folder where the OpenFaaS secrets are mounted
+var secretsDir = "/var/openfaas/secrets/"
 
+// defaultPushInterval is the minimum time between pushes of the metrics when
+// "METRICS_PUSH_INTERVAL" is not set
+const defaultPushInterval = 10 * time.Second
+
+var (
+	metricsPusher     *metrics.Pusher
+	metricsPusherOnce sync.Once
+)
+
+// Handle a serverless request. The result of routing each record of the
+// event is returned as JSON, failing with a non-2xx status if any of them
+// could not be routed
+func Handle(w http.ResponseWriter, r *http.Request) {
 	// Get the config file name from "CONFIG_FILE" environment variable
 	configFileName, ok := os.LookupEnv("CONFIG_FILE")
 	if !ok {
 		configFileName = "config"
 	}
-	configFile, err := os.Open("/var/openfaas/secrets/" + configFileName)
-	if err != nil {
-		log.Println("Error opening config file")
-		return ""
-	}
-	defer configFile.Close()
+	handleEvent(w, r, secretsDir+configFileName)
 
-	config, err := config.ReadConfig(configFile)
-	if err != nil {
-		log.Println(err.Error())
-		return ""
+	// The watchdog can't expose the metrics, push them if a Pushgateway is set
+	if pushgatewayURL, ok := os.LookupEnv("METRICS_PUSHGATEWAY_URL"); ok {
+		metricsPusherOnce.Do(func() {
+			metricsPusher = newMetricsPusher(pushgatewayURL)
+		})
+		if metricsPusher != nil {
+			metricsPusher.Schedule()
+		}
 	}
+}
 
-	// Process event
-	event, err := events.ReadEvent(string(req))
-	if err != nil {
-		log.Println(err.Error())
-		return ""
-	}
-	log.Println("Received " + event.EventSource + " event from file '" + event.ObjectKey + "'")
-
-	// Check prefixes and suffixes
-	providersToUpload := make(map[string]string)
-	var prefixOk, suffixOk bool
-	for _, output := range config.Outputs {
-		prefixOk = false
-		suffixOk = false
-		// Prefixes
-		if len(output.Prefix) == 0 {
-			prefixOk = true
+// newMetricsPusher returns a pusher of the metrics to a Pushgateway, sending
+// them at most once every "METRICS_PUSH_INTERVAL" and when the function
+// receives a SIGTERM signal. Returns nil if the pusher can't be created
+func newMetricsPusher(pushgatewayURL string) *metrics.Pusher {
+	logger := logging.Default()
+	interval := defaultPushInterval
+	if value, ok := os.LookupEnv("METRICS_PUSH_INTERVAL"); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logger.Warn("Invalid metrics push interval, using the default one", logging.Fields{"interval": value})
 		} else {
-			for _, prefix := range output.Prefix {
-				if strings.HasPrefix(event.ObjectKey, prefix) {
-					prefixOk = true
-					break
-				}
-			}
+			interval = d
 		}
-		if prefixOk {
-			// Suffixes
-			if len(output.Suffix) == 0 {
-				suffixOk = true
-			} else {
-				for _, suffix := range output.Suffix {
-					if strings.HasSuffix(event.ObjectKey, suffix) {
-						suffixOk = true
-						break
-					}
-				}
-			}
+	}
+	pusher, err := metrics.NewPusher(pushgatewayURL, "multi-out-faas", interval, func(err error) {
+		logger.Warn("Error pushing metrics", logging.Fields{"error": err})
+	})
+	if err != nil {
+		logger.Warn("Error creating metrics pusher", logging.Fields{"error": err})
+		return nil
+	}
+
+	// Push the pending metrics before stopping, then deliver the signal
+	// again to the handlers of the template (or the default one)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := pusher.Flush(); err != nil {
+			logger.Warn("Error pushing metrics", logging.Fields{"error": err})
 		}
-		if prefixOk && suffixOk {
-			providersToUpload[output.StorageProviderName] = output.Path
+		signal.Stop(signals)
+		if process, err := os.FindProcess(os.Getpid()); err == nil {
+			process.Signal(syscall.SIGTERM)
 		}
+	}()
+	return pusher
+}
+
+// handleEvent routes the event of a request with the config file in configPath.
+// The log entries of the request are correlated with the "X-Call-Id" header
+// set by the OpenFaaS gateway (or a generated ID), returned in the response
+func handleEvent(w http.ResponseWriter, r *http.Request, configPath string) {
+	callID := r.Header.Get("X-Call-Id")
+	if callID == "" {
+		callID = logging.NewCorrelationID()
 	}
+	w.Header().Set("X-Call-Id", callID)
+	ctx := logging.NewContext(r.Context(), logging.Default().With(logging.Fields{"correlation_id": callID}))
 
-	// If file does not match with any prefix or suffix terminate the function
-	if len(providersToUpload) == 0 {
-		log.Println("The file '" + event.ObjectKey + "' does not match the specification of any output")
-		return ""
+	req, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(ctx, w, http.StatusBadRequest, "Error reading request body")
+		return
 	}
 
-	// Manage download
-	// Create temporary folder to store the downloaded file
-	dir, err := ioutil.TempDir("", "")
+	config, providerClients, err := cachedConfig.load(configPath)
 	if err != nil {
-		log.Println("Error creating file")
-		return ""
+		writeError(ctx, w, http.StatusInternalServerError, err.Error())
+		return
 	}
-	defer os.RemoveAll(dir)
-
-	// Get clients for the event source storage providers
-	providerClients := make(map[string]clients.StorageClient)
-	var fileName string
-	for name, provider := range config.StorageProviders {
-		if provider.Type == event.EventSource {
-			providerClients[name] = clients.GetClient(&provider)
-			fileName, err = providerClients[name].Download(dir, event.Path)
-			if err != nil {
-				log.Println(err.Error())
-				continue
-			}
-			log.Println("File '" + event.ObjectKey + "' successfully downloaded from storage provider '" + provider.Name + "'")
-			break
-		}
+
+	// Process event
+	eventList, err := events.ReadHTTPEvent(r.Header, req)
+	if err != nil {
+		metrics.InvalidEvents.Inc()
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if fileName == "" {
-		log.Println("The file '" + event.ObjectKey + "' cannot be downloaded from any storage provider")
-		return ""
+
+	// Report the routing decisions without transferring any file
+	if isDryRun(r) {
+		res := planEvents(ctx, config, eventList)
+		writeResponse(ctx, w, http.StatusOK, res)
+		return
 	}
 
-	// Manage upload
-	for provName, provPath := range providersToUpload {
-		client, ok := providerClients[provName]
-		uploadPath := provPath + "/" + filepath.Base(fileName)
-		// Get the client for specified output
-		if !ok {
-			for _, provider := range config.StorageProviders {
-				if provName == provider.Name {
-					client = clients.GetClient(&provider)
-					providerClients[provider.Name] = client
-				}
-			}
+	// Reject the events whose files can't be downloaded, as retrying them
+	// would always fail
+	for _, event := range eventList {
+		if event.PlanOnly() {
+			metrics.InvalidEvents.Inc()
+			writeError(ctx, w, http.StatusBadRequest, "The files of '"+event.EventSource+"' events can't be downloaded, they can only be routed in dry-run mode")
+			return
 		}
-		// Upload the file
-		err = client.Upload(fileName, uploadPath)
-		if err != nil {
-			log.Println("Error uploading file '" + fileName + "' to storage provider '" + provName + "'")
+	}
+
+	// Route every record of the event independently, reusing the clients
+	rt := router.New(config)
+	res := &response{Records: make([]router.Result, 0, len(eventList))}
+	for _, event := range eventList {
+		metrics.EventsReceived.WithLabelValues(event.EventSource).Inc()
+		var record router.Result
+		if err := rt.LoadAttributes(ctx, event, providerClients); err != nil {
+			logging.FromContext(ctx).Error("Error loading file attributes", logging.Fields{"event_key": event.ObjectKey, "error": err})
+			record = router.FailedResult(event, "Error loading the attributes of file '"+event.ObjectKey+"': "+err.Error())
 		} else {
-			log.Println("File '" + fileName + "' successfully uploaded to storage provider '" + provName + "'")
+			record = rt.Execute(ctx, rt.Route(ctx, event), providerClients)
+		}
+		metrics.Records.WithLabelValues(event.EventSource, record.Status).Inc()
+		// Store the failed records in the dead-letter destination
+		if record.Status == router.StatusFailed && config.DeadLetter != nil {
+			deadLetterPath, err := writeDeadLetter(ctx, config, req, record, providerClients)
+			logger := logging.FromContext(ctx).With(logging.Fields{"event_key": record.EventKey, "provider": config.DeadLetter.StorageProviderName})
+			if err != nil {
+				logger.Error("Error writing dead letter", logging.Fields{"error": err})
+			} else {
+				logger.Info("Dead letter written", logging.Fields{"output": deadLetterPath})
+				record.DeadLetter = deadLetterPath
+			}
 		}
+		res.Records = append(res.Records, record)
 	}
 
-	return ""
+	writeResponse(ctx, w, res.statusCode(), res)
+}
+
+// readConfigFile opens and validates the config file
+func readConfigFile(configPath string) (*config.Config, error) {
+	configFile, err := os.Open(configPath)
+	if err != nil {
+		return nil, errors.New("Error opening config file")
+	}
+	defer configFile.Close()
+	return config.ReadConfig(configFile)
 }
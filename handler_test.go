@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/logging"
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/logging"
+	"handler/function/router"
+)
+
+// newTestLocalSetup creates the input and output directories of local
+// storage providers and the config file of the function
+func newTestLocalSetup(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "multi-out-faas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"secrets", "input/videos", "output"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := `{
+		"storages": {
+			"local": [
+				{"name": "input", "directory": "` + filepath.Join(dir, "input") + `"},
+				{"name": "output", "directory": "` + filepath.Join(dir, "output") + `"}
+			]
+		},
+		"output": [
+			{"storage_name": "output", "path": "videos/{key}", "suffix": [".avi"], "mirror": true},
+			{"storage_name": "input", "path": "copies", "suffix": [".avi"]}
+		],
+		"dead_letter": {"storage_name": "output", "path": "dead-letters"}
+	}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "secrets", "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previousSecretsDir := secretsDir
+	secretsDir = filepath.Join(dir, "secrets") + "/"
+	return dir, func() {
+		secretsDir = previousSecretsDir
+		os.RemoveAll(dir)
+	}
+}
+
+func callHandle(t *testing.T, event string) (int, *response) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(event))
+	r.Header.Set("X-Call-Id", "test-call")
+	Handle(w, r)
+	if w.Header().Get("X-Call-Id") != "test-call" {
+		t.Error("Error returning the correlation ID")
+	}
+	var res response
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, &res
+}
+
+func TestHandleLocal(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(filepath.Join(dir, "input", "videos", "video.avi"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, res := callHandle(t, `{
+		"Records":[
+			{"eventSource":"local", "path":"videos/video.avi"},
+			{"eventSource":"local", "path":"videos/video.mp4"}
+		]
+	}`)
+	if code != http.StatusOK || len(res.Records) != 2 {
+		t.Fatalf("Error routing local event: %d %+v", code, res)
+	}
+	if res.Records[0].Status != router.StatusRouted || len(res.Records[0].Outputs) != 2 || res.Records[1].Status != router.StatusUnmatched {
+		t.Errorf("Error routing local event: %+v", res)
+	}
+	for _, file := range []string{"output/videos/videos/video.avi", "input/copies/video.avi"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil || string(content) != "content" {
+			t.Errorf("Error uploading file to '%s'", file)
+		}
+	}
+
+	// Deletions are propagated to the mirrored outputs only
+	code, res = callHandle(t, `{"Records":[{"eventSource":"local", "eventName":"ObjectRemoved", "path":"videos/video.avi"}]}`)
+	if code != http.StatusOK || res.Records[0].Status != router.StatusRouted || len(res.Records[0].Outputs) != 1 {
+		t.Fatalf("Error routing removed local event: %d %+v", code, res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output/videos/videos/video.avi")); !os.IsNotExist(err) {
+		t.Error("Error deleting file from mirrored output")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "input/copies/video.avi")); err != nil {
+		t.Error("Error keeping file in output without mirror")
+	}
+}
+
+func TestHandleLocalMissingFile(t *testing.T) {
+	_, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+
+	var logs bytes.Buffer
+	logging.SetOutput(&logs)
+	defer logging.SetOutput(os.Stderr)
+	code, res := callHandle(t, `{"Records":[{"eventSource":"local", "path":"videos/missing.avi"}]}`)
+	if !strings.Contains(logs.String(), `"level":"warn","msg":"Error opening file","correlation_id":"test-call","error":`) {
+		t.Errorf("Error logging with the correlation ID:\n%s", logs.String())
+	}
+	if code != http.StatusInternalServerError || res.Records[0].Status != router.StatusFailed {
+		t.Errorf("Error reporting missing local file: %d %+v", code, res)
+	}
+	for _, output := range res.Records[0].Outputs {
+		if output.Status != router.StatusSkipped {
+			t.Errorf("Error skipping outputs of missing local file: %+v", output)
+		}
+	}
+}
+
+func TestHandleLocalDeadLetter(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(filepath.Join(dir, "input", "videos", "video.avi"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The upload to the first output fails, as its folder can't be created
+	if err := ioutil.WriteFile(filepath.Join(dir, "output", "videos"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	event := `{"Records":[{"eventSource":"local", "path":"videos/video.avi"}]}`
+	code, res := callHandle(t, event)
+	if code != http.StatusInternalServerError || len(res.Records) != 1 || res.Records[0].Status != router.StatusFailed {
+		t.Fatalf("Error reporting failed record: %d %+v", code, res)
+	}
+	record := res.Records[0]
+	if !strings.HasPrefix(record.DeadLetter, "dead-letters/") || !strings.HasSuffix(record.DeadLetter, "-videos_video.avi.json") {
+		t.Fatalf("Error returning the dead-letter path: '%s'", record.DeadLetter)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "output", record.DeadLetter))
+	if err != nil {
+		t.Fatalf("Error writing dead letter: %v", err)
+	}
+	var doc deadLetter
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("Error reading dead letter: %v", err)
+	}
+	var storedEvent, originalEvent interface{}
+	json.Unmarshal(doc.Event, &storedEvent)
+	json.Unmarshal([]byte(event), &originalEvent)
+	if !reflect.DeepEqual(storedEvent, originalEvent) {
+		t.Errorf("Error storing the original event in the dead letter: %s", doc.Event)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, doc.Time); err != nil {
+		t.Errorf("Error storing the time of the dead letter: %v", err)
+	}
+	outputs := doc.Record.Outputs
+	if doc.Record.EventKey != "videos/video.avi" || doc.Record.Status != router.StatusFailed || len(outputs) != 2 {
+		t.Fatalf("Error storing the failed record in the dead letter: %+v", doc.Record)
+	}
+	if outputs[0].Path != "videos/videos/video.avi" || outputs[0].Status != router.StatusFailed || !strings.Contains(outputs[0].Error, "Error creating folder") {
+		t.Errorf("Error storing the error of the failed output: %+v", outputs[0])
+	}
+	if outputs[1].Status != router.StatusSuccess || outputs[1].Error != "" {
+		t.Errorf("Error storing the result of the successful output: %+v", outputs[1])
+	}
+}
+
+func TestHandleLocalAttributesError(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	// The size filter requires reading the attributes of the missing file
+	config := `{
+		"storages": {"local": [{"name": "input", "directory": "` + filepath.Join(dir, "input") + `"}]},
+		"output": [{"storage_name": "input", "path": "small", "max_size": 1024}],
+		"dead_letter": {"storage_name": "input", "path": "dead-letters"}
+	}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "secrets", "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, res := callHandle(t, `{"Records":[{"eventSource":"local", "path":"videos/missing.avi"}]}`)
+	if code != http.StatusInternalServerError || len(res.Records) != 1 {
+		t.Fatalf("Error reporting unreadable file attributes: %d %+v", code, res)
+	}
+	record := res.Records[0]
+	if record.Status != router.StatusFailed || !strings.HasPrefix(record.Error, "Error loading the attributes of file 'videos/missing.avi'") || len(record.Outputs) != 0 {
+		t.Errorf("Error reporting unreadable file attributes: %+v", record)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "input", record.DeadLetter)); record.DeadLetter == "" || err != nil {
+		t.Errorf("Error writing dead letter of unreadable file attributes: '%s'", record.DeadLetter)
+	}
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logging provides leveled and structured logging, written as JSON
+// lines (or as text) to be parsed by log pipelines. The level and format are
+// set with the "LOG_LEVEL" and "LOG_FORMAT" environment variables
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level of the log entries
+type Level int
+
+// Log levels
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return InfoLevel, errors.New("unknown log level '" + name + "', valid levels are: " + strings.Join(levelNames, ", "))
+}
+
+// Log formats
+const (
+	JSONFormat = "json"
+	TextFormat = "text"
+)
+
+// Fields of a log entry
+type Fields map[string]interface{}
+
+// output struct to represent the destination shared by all the loggers
+type output struct {
+	mu     sync.Mutex
+	writer io.Writer
+	level  Level
+	format string
+}
+
+var out = &output{
+	writer: os.Stderr,
+	level:  InfoLevel,
+	format: JSONFormat,
+}
+
+func init() {
+	if err := Configure(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")); err != nil {
+		Default().Warn("Invalid logging configuration", Fields{"error": err})
+	}
+}
+
+// Configure sets the minimum level and the format ("json" or "text") of the
+// log entries. Empty values are ignored
+func Configure(level, format string) error {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if level != "" {
+		l, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		out.level = l
+	}
+	switch strings.ToLower(format) {
+	case "":
+	case JSONFormat, TextFormat:
+		out.format = strings.ToLower(format)
+	default:
+		return errors.New("unknown log format '" + format + "', valid formats are: json, text")
+	}
+	return nil
+}
+
+// SetOutput sets the writer of the log entries (stderr by default)
+func SetOutput(w io.Writer) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.writer = w
+}
+
+// Logger struct to represent a logger adding its fields to every entry
+type Logger struct {
+	fields Fields
+}
+
+var defaultLogger = &Logger{}
+
+// Default returns the logger without fields
+func Default() *Logger {
+	return defaultLogger
+}
+
+// With returns a logger with the fields added to the ones of l
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{fields: merged}
+}
+
+// Debug writes a debug entry with the fields of the logger and the given ones (can be nil)
+func (l *Logger) Debug(msg string, fields Fields) {
+	l.log(DebugLevel, msg, fields)
+}
+
+// Info writes an info entry with the fields of the logger and the given ones (can be nil)
+func (l *Logger) Info(msg string, fields Fields) {
+	l.log(InfoLevel, msg, fields)
+}
+
+// Warn writes a warning entry with the fields of the logger and the given ones (can be nil)
+func (l *Logger) Warn(msg string, fields Fields) {
+	l.log(WarnLevel, msg, fields)
+}
+
+// Error writes an error entry with the fields of the logger and the given ones (can be nil)
+func (l *Logger) Error(msg string, fields Fields) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+func (l *Logger) log(level Level, msg string, fields Fields) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if level < out.level {
+		return
+	}
+	if len(fields) > 0 {
+		fields = l.With(fields).fields
+	} else {
+		fields = l.fields
+	}
+	now := time.Now().UTC()
+	if out.format == TextFormat {
+		io.WriteString(out.writer, formatText(now, level, msg, fields))
+	} else {
+		io.WriteString(out.writer, formatJSON(now, level, msg, fields))
+	}
+}
+
+// formatJSON writes the entry as a JSON object with the time, level and
+// message first, followed by the fields sorted by name
+func formatJSON(t time.Time, level Level, msg string, fields Fields) string {
+	var sb strings.Builder
+	sb.WriteString(`{"time":"` + t.Format(time.RFC3339Nano) + `","level":"` + level.String() + `","msg":`)
+	sb.Write(marshal(msg))
+	for _, key := range sortedKeys(fields) {
+		sb.WriteString(",")
+		sb.Write(marshal(key))
+		sb.WriteString(":")
+		sb.Write(marshal(fieldValue(fields[key])))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// formatText writes the entry as "<time> <LEVEL> <msg> key=value..."
+func formatText(t time.Time, level Level, msg string, fields Fields) string {
+	var sb strings.Builder
+	sb.WriteString(t.Format(time.RFC3339Nano) + " " + strings.ToUpper(level.String()) + " " + msg)
+	for _, key := range sortedKeys(fields) {
+		value := fmt.Sprint(fieldValue(fields[key]))
+		if strings.ContainsAny(value, " \"=") || value == "" {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(" " + key + "=" + value)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// fieldValue returns the value to log, using the message of errors
+func fieldValue(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return v
+}
+
+func marshal(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return b
+}
+
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by the context or the default one
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return defaultLogger
+}
+
+// NewCorrelationID returns a random ID to correlate the entries of a request
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
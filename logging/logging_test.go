@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureOutput(t *testing.T, level, format string, fn func()) string {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	if err := Configure(level, format); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		SetOutput(os.Stderr)
+		Configure("info", "json")
+	}()
+	fn()
+	return buf.String()
+}
+
+func TestJSONFormat(t *testing.T) {
+	out := captureOutput(t, "info", "json", func() {
+		logger := Default().With(Fields{"correlation_id": "abc", "event_key": "file.txt"})
+		logger.Debug("Hidden entry", nil)
+		logger.Error("Error uploading file", Fields{"error": errors.New("timeout"), "duration_ms": 1.5})
+	})
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Error filtering entries by level:\n%s", out)
+	}
+	if !strings.HasPrefix(lines[0], `{"time":"`) || !strings.Contains(lines[0], `"level":"error","msg":"Error uploading file","correlation_id":"abc","duration_ms":1.5,"error":"timeout","event_key":"file.txt"}`) {
+		t.Errorf("Error formatting JSON entry: %s", lines[0])
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	out := captureOutput(t, "debug", "text", func() {
+		Default().Debug("File opened", Fields{"provider": "minio", "output": "my bucket/file.txt"})
+	})
+	if !strings.HasSuffix(out, ` DEBUG File opened output="my bucket/file.txt" provider=minio`+"\n") {
+		t.Errorf("Error formatting text entry: %s", out)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	if err := Configure("verbose", ""); err == nil {
+		t.Error("Error reporting invalid level")
+	}
+	if err := Configure("", "xml"); err == nil {
+		t.Error("Error reporting invalid format")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Error("Error getting default logger")
+	}
+	logger := Default().With(Fields{"correlation_id": "abc"})
+	if FromContext(NewContext(context.Background(), logger)) != logger {
+		t.Error("Error getting logger from context")
+	}
+	if id := NewCorrelationID(); len(id) != 32 || id == NewCorrelationID() {
+		t.Errorf("Error generating correlation ID '%s'", id)
+	}
+}
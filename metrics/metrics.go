@@ -0,0 +1,425 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics defines the Prometheus metrics of the function, which can be
+// exposed by the standalone server or pushed to a Pushgateway.
+// Only the counters and histograms used by the function are implemented,
+// written in the Prometheus text exposition format
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	namespace   = "multi_out_faas"
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// collector interface for the metrics that can be written
+type collector interface {
+	write(w io.Writer)
+}
+
+// registry struct to represent a set of metrics written in order
+type registry struct {
+	collectors []collector
+}
+
+func (r *registry) register(c ...collector) {
+	r.collectors = append(r.collectors, c...)
+}
+
+func (r *registry) write(w io.Writer) {
+	for _, c := range r.collectors {
+		c.write(w)
+	}
+}
+
+// Registry contains all the metrics of the function
+var Registry = &registry{}
+
+var (
+	// EventsReceived counts the event records received by source
+	EventsReceived = NewCounterVec("events_received_total", "Number of event records received.", "source")
+
+	// InvalidEvents counts the requests whose event couldn't be parsed
+	InvalidEvents = NewCounter("invalid_events_total", "Number of requests with an invalid event.")
+
+	// Records counts the processed event records by source and status
+	// ("routed", "unmatched" or "failed")
+	Records = NewCounterVec("records_total", "Number of event records processed, by routing status.", "source", "status")
+
+	// Downloads counts the attempts to open the source files by storage
+	// provider and outcome ("success" or "failed")
+	Downloads = NewCounterVec("downloads_total", "Number of attempts to open the source files.", "source", "storage_name", "outcome")
+
+	// Transfers counts the operations on the outputs by method ("stream",
+	// "copy" or "delete") and outcome ("success" or "failed")
+	Transfers = NewCounterVec("transfers_total", "Number of uploads, copies and deletions in the outputs.", "source", "storage_name", "method", "outcome")
+
+	// TransferredBytes counts the bytes streamed to the outputs
+	TransferredBytes = NewCounterVec("transferred_bytes_total", "Number of bytes streamed to the outputs.", "source", "storage_name")
+
+	// TransferDuration observes the duration of the operations on the
+	// outputs, including their retries
+	TransferDuration = NewHistogramVec("transfer_duration_seconds", "Duration of the uploads, copies and deletions in the outputs.",
+		[]float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}, "source", "storage_name", "method", "outcome")
+)
+
+func init() {
+	Registry.register(EventsReceived, InvalidEvents, Records, Downloads, Transfers, TransferredBytes, TransferDuration)
+}
+
+// Handler returns the HTTP handler exposing the metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		Registry.write(w)
+	})
+}
+
+// pushTimeout is the maximum duration of a push to the Pushgateway
+const pushTimeout = 10 * time.Second
+
+// Pusher struct to represent the periodic push of the metrics to a
+// Pushgateway. The pushes requested during an interval are sent together
+// once it ends, so bursts of invocations don't flood the Pushgateway
+type Pusher struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	// onError is called with the errors of the scheduled pushes
+	onError func(err error)
+	// mu protects timer, which is set while a push is scheduled
+	mu    sync.Mutex
+	timer *time.Timer
+	// pushMu ensures that only one push is sent at a time
+	pushMu sync.Mutex
+}
+
+// NewPusher returns a pusher of the metrics to a Pushgateway, replacing the
+// ones previously pushed by this instance (grouped by hostname, as each
+// function replica has its own counters)
+func NewPusher(pushgatewayURL, job string, interval time.Duration, onError func(err error)) (*Pusher, error) {
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return &Pusher{
+		url:      strings.TrimRight(pushgatewayURL, "/") + "/metrics/job/" + url.PathEscape(job) + "/instance/" + url.PathEscape(instance),
+		client:   &http.Client{Timeout: pushTimeout},
+		interval: interval,
+		onError:  onError,
+	}, nil
+}
+
+// Schedule pushes the metrics once the current interval ends, unless a push
+// is already scheduled
+func (p *Pusher) Schedule() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		return
+	}
+	p.timer = time.AfterFunc(p.interval, func() {
+		p.mu.Lock()
+		p.timer = nil
+		p.mu.Unlock()
+		if err := p.push(); err != nil && p.onError != nil {
+			p.onError(err)
+		}
+	})
+}
+
+// Flush pushes the metrics immediately, cancelling the scheduled push
+// (e.g. when the function is stopped)
+func (p *Pusher) Flush() error {
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+	return p.push()
+}
+
+// push sends the current value of the metrics
+func (p *Pusher) push() error {
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+	var body bytes.Buffer
+	Registry.write(&body)
+
+	req, err := http.NewRequest(http.MethodPut, p.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.New("Pushgateway returned status " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+// Counter struct to represent a counter without labels
+type Counter struct {
+	name  string
+	help  string
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounter returns a counter, its name is prefixed with the namespace
+func NewCounter(name, help string) *Counter {
+	return &Counter{name: namespace + "_" + name, help: help}
+}
+
+// Inc increments the counter by 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by v
+func (c *Counter) Add(v float64) {
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %s\n", c.name, formatValue(c.value))
+}
+
+// CounterVec struct to represent a counter for each combination of labels
+type CounterVec struct {
+	name     string
+	help     string
+	labels   []string
+	mu       sync.Mutex
+	counters map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	values []string
+	value  float64
+}
+
+// LabeledCounter struct to represent the counter of a combination of labels
+type LabeledCounter struct {
+	vec     *CounterVec
+	counter *labeledCounter
+}
+
+// NewCounterVec returns a counter with labels, its name is prefixed with the namespace
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:     namespace + "_" + name,
+		help:     help,
+		labels:   labels,
+		counters: make(map[string]*labeledCounter),
+	}
+}
+
+// WithLabelValues returns the counter of the label values, which must be
+// given in the same order as the labels
+func (cv *CounterVec) WithLabelValues(values ...string) *LabeledCounter {
+	if len(values) != len(cv.labels) {
+		panic("metrics: wrong number of label values for " + cv.name)
+	}
+	key := strings.Join(values, "\xff")
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	counter, ok := cv.counters[key]
+	if !ok {
+		counter = &labeledCounter{values: values}
+		cv.counters[key] = counter
+	}
+	return &LabeledCounter{vec: cv, counter: counter}
+}
+
+// Inc increments the counter by 1
+func (lc *LabeledCounter) Inc() {
+	lc.Add(1)
+}
+
+// Add increments the counter by v
+func (lc *LabeledCounter) Add(v float64) {
+	lc.vec.mu.Lock()
+	lc.counter.value += v
+	lc.vec.mu.Unlock()
+}
+
+func (cv *CounterVec) write(w io.Writer) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	writeHeader(w, cv.name, cv.help, "counter")
+	for _, key := range sortedKeys(cv.counters) {
+		counter := cv.counters[key]
+		fmt.Fprintf(w, "%s%s %s\n", cv.name, formatLabels(cv.labels, counter.values, "", ""), formatValue(counter.value))
+	}
+}
+
+// HistogramVec struct to represent a histogram for each combination of labels
+type HistogramVec struct {
+	name       string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	histograms map[string]*labeledHistogram
+}
+
+type labeledHistogram struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// LabeledHistogram struct to represent the histogram of a combination of labels
+type LabeledHistogram struct {
+	vec       *HistogramVec
+	histogram *labeledHistogram
+}
+
+// NewHistogramVec returns a histogram with labels and the given upper bounds
+// of the buckets, its name is prefixed with the namespace
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		name:       namespace + "_" + name,
+		help:       help,
+		labels:     labels,
+		buckets:    buckets,
+		histograms: make(map[string]*labeledHistogram),
+	}
+}
+
+// WithLabelValues returns the histogram of the label values, which must be
+// given in the same order as the labels
+func (hv *HistogramVec) WithLabelValues(values ...string) *LabeledHistogram {
+	if len(values) != len(hv.labels) {
+		panic("metrics: wrong number of label values for " + hv.name)
+	}
+	key := strings.Join(values, "\xff")
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	histogram, ok := hv.histograms[key]
+	if !ok {
+		histogram = &labeledHistogram{values: values, counts: make([]uint64, len(hv.buckets))}
+		hv.histograms[key] = histogram
+	}
+	return &LabeledHistogram{vec: hv, histogram: histogram}
+}
+
+// Observe adds a value to the histogram
+func (lh *LabeledHistogram) Observe(v float64) {
+	lh.vec.mu.Lock()
+	defer lh.vec.mu.Unlock()
+	for i, bound := range lh.vec.buckets {
+		if v <= bound {
+			lh.histogram.counts[i]++
+		}
+	}
+	lh.histogram.count++
+	lh.histogram.sum += v
+}
+
+func (hv *HistogramVec) write(w io.Writer) {
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	writeHeader(w, hv.name, hv.help, "histogram")
+	for _, key := range sortedKeys(hv.histograms) {
+		histogram := hv.histograms[key]
+		for i, bound := range hv.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", hv.name, formatLabels(hv.labels, histogram.values, "le", formatValue(bound)), histogram.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", hv.name, formatLabels(hv.labels, histogram.values, "le", "+Inf"), histogram.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", hv.name, formatLabels(hv.labels, histogram.values, "", ""), formatValue(histogram.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", hv.name, formatLabels(hv.labels, histogram.values, "", ""), histogram.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// formatLabels returns the labels of a sample, adding the extra label if set
+func formatLabels(labels, values []string, extraLabel, extraValue string) string {
+	var pairs []string
+	for i, label := range labels {
+		pairs = append(pairs, label+"=\""+escapeLabelValue(values[i])+"\"")
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, extraLabel+"=\""+extraValue+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*labeledCounter:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*labeledHistogram:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
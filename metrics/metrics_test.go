@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	counter := NewCounter("test_total", "Test counter.")
+	counter.Inc()
+	counterVec := NewCounterVec("test_labeled_total", "Test labeled counter.", "source", "status")
+	counterVec.WithLabelValues("minio", "routed").Add(2)
+	counterVec.WithLabelValues("minio", "routed").Inc()
+	counterVec.WithLabelValues("s3", `"failed"`).Inc()
+	histogramVec := NewHistogramVec("test_seconds", "Test histogram.", []float64{0.1, 1}, "source")
+	histogramVec.WithLabelValues("minio").Observe(0.5)
+	histogramVec.WithLabelValues("minio").Observe(2)
+
+	r := &registry{}
+	r.register(counter, counterVec, histogramVec)
+	var out bytes.Buffer
+	r.write(&out)
+
+	expected := `# HELP multi_out_faas_test_total Test counter.
+# TYPE multi_out_faas_test_total counter
+multi_out_faas_test_total 1
+# HELP multi_out_faas_test_labeled_total Test labeled counter.
+# TYPE multi_out_faas_test_labeled_total counter
+multi_out_faas_test_labeled_total{source="minio",status="routed"} 3
+multi_out_faas_test_labeled_total{source="s3",status="\"failed\""} 1
+# HELP multi_out_faas_test_seconds Test histogram.
+# TYPE multi_out_faas_test_seconds histogram
+multi_out_faas_test_seconds_bucket{source="minio",le="0.1"} 0
+multi_out_faas_test_seconds_bucket{source="minio",le="1"} 1
+multi_out_faas_test_seconds_bucket{source="minio",le="+Inf"} 2
+multi_out_faas_test_seconds_sum{source="minio"} 2.5
+multi_out_faas_test_seconds_count{source="minio"} 2
+`
+	if out.String() != expected {
+		t.Errorf("Error writing metrics:\n%s", out.String())
+	}
+}
+
+func TestPusher(t *testing.T) {
+	var mu sync.Mutex
+	var method, path, body string
+	pushes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		method, path, body = r.Method, r.URL.Path, string(content)
+		pushes++
+	}))
+	defer server.Close()
+
+	pusher, err := NewPusher(server.URL+"/", "multi-out-faas", 50*time.Millisecond, func(err error) {
+		t.Errorf("Error pushing metrics: %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pusher.client.Timeout == 0 {
+		t.Error("Error setting the timeout of the pushes")
+	}
+
+	// The pushes scheduled during an interval are sent together
+	InvalidEvents.Inc()
+	for i := 0; i < 100; i++ {
+		pusher.Schedule()
+	}
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	hostname, _ := os.Hostname()
+	if pushes != 1 || method != http.MethodPut || path != "/metrics/job/multi-out-faas/instance/"+hostname {
+		t.Errorf("Error pushing metrics: %d pushes, %s %s", pushes, method, path)
+	}
+	if !strings.Contains(body, "multi_out_faas_invalid_events_total ") {
+		t.Error("Error pushing metrics")
+	}
+	mu.Unlock()
+
+	// Flushing cancels the scheduled push
+	pusher.Schedule()
+	if err := pusher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if pushes != 2 {
+		t.Errorf("Error flushing metrics: %d pushes", pushes)
+	}
+	mu.Unlock()
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	//"github.com/grycap/multi-out-faas/logging"
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/logging"
+	"handler/function/router"
+)
+
+// response struct to represent the result of the function
+type response struct {
+	// Set if the files haven't been transferred (dry-run mode)
+	DryRun  bool            `json:"dry_run,omitempty"`
+	Records []router.Result `json:"records"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// statusCode returns the HTTP status code for the response,
+// failing if any record could not be routed
+func (r *response) statusCode() int {
+	if r.Error != "" {
+		return http.StatusInternalServerError
+	}
+	for _, record := range r.Records {
+		if record.Status == router.StatusFailed {
+			return http.StatusInternalServerError
+		}
+	}
+	return http.StatusOK
+}
+
+// writeResponse writes the response as JSON with the given status code
+func writeResponse(ctx context.Context, w http.ResponseWriter, statusCode int, res interface{}) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error encoding response", logging.Fields{"error": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// writeError logs the error and writes it as response
+func writeError(ctx context.Context, w http.ResponseWriter, statusCode int, err string) {
+	logging.FromContext(ctx).Error(err, logging.Fields{"status": statusCode})
+	writeResponse(ctx, w, statusCode, &response{
+		Records: []router.Result{},
+		Error:   err,
+	})
+}
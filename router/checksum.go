@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/events"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/events"
+)
+
+// User metadata keys of the stored checksums, also used to verify the files
+const (
+	sha256MetadataKey = "sha256"
+	crc32cMetadataKey = "crc32c"
+)
+
+// sidecarExtension is the extension of the files storing the checksums
+const sidecarExtension = ".sha256"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksums struct to represent the hex encoded checksums of a file (empty
+// values are unknown)
+type checksums struct {
+	md5    string
+	sha256 string
+	crc32c string
+}
+
+// hasher computes the checksums of the data written to it
+type hasher struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc32c hash.Hash32
+	writer io.Writer
+}
+
+// newHasher returns a hasher of MD5 and SHA-256, and optionally CRC32C
+func newHasher(crc32c bool) *hasher {
+	h := &hasher{md5: md5.New(), sha256: sha256.New()}
+	writers := []io.Writer{h.md5, h.sha256}
+	if crc32c {
+		h.crc32c = crc32.New(crc32cTable)
+		writers = append(writers, h.crc32c)
+	}
+	h.writer = io.MultiWriter(writers...)
+	return h
+}
+
+func (h *hasher) Write(p []byte) (int, error) {
+	return h.writer.Write(p)
+}
+
+// sums returns the checksums of the data written
+func (h *hasher) sums() *checksums {
+	sums := &checksums{
+		md5:    hex.EncodeToString(h.md5.Sum(nil)),
+		sha256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+	if h.crc32c != nil {
+		sums.crc32c = hex.EncodeToString(h.crc32c.Sum(nil))
+	}
+	return sums
+}
+
+// expectedChecksums returns the checksums of the source file of an event: the
+// MD5 of its ETag, unless it's from a multipart upload, and the ones of its
+// "sha256" and "crc32c" metadata
+func expectedChecksums(event *events.Event) *checksums {
+	expected := &checksums{
+		sha256: strings.ToLower(event.Metadata[sha256MetadataKey]),
+		crc32c: strings.ToLower(event.Metadata[crc32cMetadataKey]),
+	}
+	etag := strings.ToLower(strings.Trim(event.ETag, `"`))
+	if _, err := hex.DecodeString(etag); err == nil && len(etag) == 2*md5.Size {
+		expected.md5 = etag
+	}
+	return expected
+}
+
+// verify returns an error if any of the known checksums doesn't match
+func (c *checksums) verify(sums *checksums) error {
+	for _, check := range []struct{ name, expected, actual string }{
+		{"MD5", c.md5, sums.md5},
+		{"SHA-256", c.sha256, sums.sha256},
+		{"CRC32C", c.crc32c, sums.crc32c},
+	} {
+		if check.expected != "" && check.actual != "" && check.expected != check.actual {
+			return errors.New("Checksum mismatch: the " + check.name + " of the downloaded file is " + check.actual + ", expected " + check.expected)
+		}
+	}
+	return nil
+}
+
+// storeChecksums stores the checksums of an uploaded file as metadata, copying
+// the file over itself with the checksums added to its metadata (in parts if
+// it's larger than 5 GiB), or writes them to a sidecar file in the format of
+// sha256sum
+func storeChecksums(ctx context.Context, target *uploadTarget, sums *checksums) error {
+	switch target.checksum.Store {
+	case config.ChecksumMetadata:
+		copier, ok := target.client.(clients.Copier)
+		if !ok {
+			return errors.New("Error storing checksums: the storage provider '" + target.provider + "' can't update the metadata of files")
+		}
+		metadata := &clients.Metadata{UserMetadata: map[string]string{}}
+		if target.metadata != nil {
+			*metadata = *target.metadata
+			metadata.UserMetadata = make(map[string]string, len(target.metadata.UserMetadata)+2)
+			for key, value := range target.metadata.UserMetadata {
+				metadata.UserMetadata[key] = value
+			}
+		}
+		metadata.UserMetadata[sha256MetadataKey] = sums.sha256
+		if sums.crc32c != "" {
+			metadata.UserMetadata[crc32cMetadataKey] = sums.crc32c
+		}
+		return copier.Copy(ctx, target.path, target.path, metadata)
+	case config.ChecksumSidecar:
+		content := sums.sha256 + "  " + path.Base(target.path) + "\n"
+		return target.client.Put(ctx, strings.NewReader(content), target.path+sidecarExtension, &clients.Metadata{ContentType: "text/plain"})
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/events"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/events"
+)
+
+const (
+	contentMD5    = "9a0364b9e99bb480dd25e1f0284c8555"
+	contentSHA256 = "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+	contentCRC32C = "61af7533"
+)
+
+func TestHasher(t *testing.T) {
+	h := newHasher(true)
+	h.Write([]byte("con"))
+	h.Write([]byte("tent"))
+	expected := &checksums{md5: contentMD5, sha256: contentSHA256, crc32c: contentCRC32C}
+	if sums := h.sums(); !reflect.DeepEqual(sums, expected) {
+		t.Errorf("Error computing checksums. Expected %+v, got %+v", expected, sums)
+	}
+	if sums := newHasher(false).sums(); sums.crc32c != "" {
+		t.Error("Error computing CRC32C checksum when it's disabled")
+	}
+}
+
+func TestExpectedChecksums(t *testing.T) {
+	tests := []struct {
+		event    events.Event
+		expected checksums
+	}{
+		{events.Event{ETag: `"` + strings.ToUpper(contentMD5) + `"`}, checksums{md5: contentMD5}},
+		{events.Event{ETag: "d41d8cd98f00b204e9800998ecf8427e-2"}, checksums{}},
+		{events.Event{ETag: "0x8D7E3B3C1F1B7C9"}, checksums{}},
+		{events.Event{Metadata: map[string]string{"sha256": contentSHA256, "crc32c": contentCRC32C}}, checksums{sha256: contentSHA256, crc32c: contentCRC32C}},
+	}
+
+	for _, test := range tests {
+		if expected := expectedChecksums(&test.event); !reflect.DeepEqual(*expected, test.expected) {
+			t.Errorf("Error reading the checksums of %+v. Expected %+v, got %+v", test.event, test.expected, *expected)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	sums := &checksums{md5: contentMD5, sha256: contentSHA256}
+	for _, expected := range []checksums{{}, {md5: contentMD5}, {sha256: contentSHA256, crc32c: contentCRC32C}} {
+		if err := expected.verify(sums); err != nil {
+			t.Errorf("Error verifying matching checksums %+v: %v", expected, err)
+		}
+	}
+	for _, expected := range []checksums{{md5: contentSHA256[:32]}, {md5: contentMD5, sha256: contentMD5}} {
+		if err := expected.verify(sums); err == nil {
+			t.Errorf("Error reporting checksum mismatch %+v", expected)
+		}
+	}
+}
+
+func TestStreamToTargetsChecksum(t *testing.T) {
+	verified := newFakeClient()
+	unverified := newFakeClient()
+	targets := []uploadTarget{
+		{provider: "verified", path: "bucket/file", client: verified, checksum: &config.Checksum{Verify: true}},
+		{provider: "unverified", path: "bucket/file", client: unverified},
+	}
+
+	results := streamToTargets(context.Background(), strings.NewReader("content"), targets, &checksums{md5: contentMD5})
+	if results[0].err != nil || results[1].err != nil {
+		t.Errorf("Error streaming file with valid checksum: %v, %v", results[0].err, results[1].err)
+	}
+	if results[0].sums == nil || results[0].sums.sha256 != contentSHA256 || results[1].sums != nil {
+		t.Error("Error returning the checksums of the targets with checksum options")
+	}
+
+	verified = newFakeClient()
+	unverified = newFakeClient()
+	targets[0].client = verified
+	targets[1].client = unverified
+	results = streamToTargets(context.Background(), strings.NewReader("altered"), targets, &checksums{md5: contentMD5})
+	if results[0].err == nil || !strings.Contains(results[0].err.Error(), "Checksum mismatch") {
+		t.Errorf("Error reporting checksum mismatch: %v", results[0].err)
+	}
+	if _, ok := verified.files["bucket/file"]; ok {
+		t.Error("Error uploading file with checksum mismatch")
+	}
+	if results[1].err != nil || unverified.files["bucket/file"] != "altered" {
+		t.Error("Error uploading file to target without verification")
+	}
+
+	// The checksums of the files with a mismatch aren't stored
+	stored := newFakeClient()
+	targets = []uploadTarget{
+		{provider: "stored", path: "bucket/metadata/file", client: stored, checksum: &config.Checksum{Store: config.ChecksumMetadata}},
+		{provider: "stored", path: "bucket/sidecar/file", client: stored, checksum: &config.Checksum{Store: config.ChecksumSidecar}},
+	}
+	results = streamToTargets(context.Background(), strings.NewReader("altered"), targets, &checksums{md5: contentMD5})
+	if results[0].err != nil || results[1].err != nil || stored.files["bucket/metadata/file"] != "altered" {
+		t.Errorf("Error uploading file with checksum mismatch without verification: %v, %v", results[0].err, results[1].err)
+	}
+	if stored.metadata["bucket/metadata/file"] != nil {
+		t.Errorf("Error storing checksums of file with checksum mismatch: %+v", stored.metadata["bucket/metadata/file"])
+	}
+	if _, ok := stored.files["bucket/sidecar/file.sha256"]; ok {
+		t.Error("Error writing sidecar file of file with checksum mismatch")
+	}
+}
+
+func TestStoreChecksums(t *testing.T) {
+	client := newFakeClient()
+	targets := []uploadTarget{
+		{
+			provider: "client",
+			path:     "bucket/metadata/file.txt",
+			client:   client,
+			metadata: &clients.Metadata{ContentType: "text/plain", UserMetadata: map[string]string{"owner": "me"}},
+			checksum: &config.Checksum{Store: config.ChecksumMetadata, CRC32C: true},
+		},
+		{
+			provider: "client",
+			path:     "bucket/sidecar/file.txt",
+			client:   client,
+			checksum: &config.Checksum{Store: config.ChecksumSidecar},
+		},
+	}
+
+	results := streamToTargets(context.Background(), strings.NewReader("content"), targets, nil)
+	if results[0].err != nil || results[1].err != nil {
+		t.Fatalf("Error storing checksums: %v, %v", results[0].err, results[1].err)
+	}
+	expected := &clients.Metadata{
+		ContentType:  "text/plain",
+		UserMetadata: map[string]string{"owner": "me", "sha256": contentSHA256, "crc32c": contentCRC32C},
+	}
+	if metadata := client.metadata["bucket/metadata/file.txt"]; !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Error storing checksums as metadata. Expected %+v, got %+v", expected, metadata)
+	}
+	if len(targets[0].metadata.UserMetadata) != 1 {
+		t.Error("Error modifying the metadata of the target")
+	}
+	if sidecar := client.files["bucket/sidecar/file.txt.sha256"]; sidecar != contentSHA256+"  file.txt\n" {
+		t.Errorf("Error storing checksums in sidecar file: %q", sidecar)
+	}
+}
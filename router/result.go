@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+// Record and output status values
+const (
+	StatusRouted    = "routed"
+	StatusUnmatched = "unmatched"
+	StatusFailed    = "failed"
+	StatusSuccess   = "success"
+	StatusSkipped   = "skipped"
+	// Outputs and source actions that would be applied (see Plan.Result)
+	StatusPlanned = "planned"
+)
+
+// Transfer methods
+const (
+	MethodStream = "stream"
+	MethodCopy   = "copy"
+	MethodDelete = "delete"
+)
+
+// Result struct to represent the result of routing an event record
+type Result struct {
+	EventKey string         `json:"event_key"`
+	Source   string         `json:"source"`
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Outputs  []OutputResult `json:"outputs"`
+	// Result of the action applied to the source file once routed
+	SourceAction *SourceActionResult `json:"source_action,omitempty"`
+	// Path of the file stored in the dead-letter destination
+	DeadLetter string `json:"dead_letter,omitempty"`
+}
+
+// SourceActionResult struct to represent the result of deleting or moving
+// the source file
+type SourceActionResult struct {
+	Action string `json:"action"`
+	// Archive path of the moved file
+	Path   string `json:"path,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// OutputResult struct to represent the result of the upload to an output
+type OutputResult struct {
+	StorageName string `json:"storage_name"`
+	Path        string `json:"path"`
+	Method      string `json:"method,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	// Hex encoded checksums of the uploaded file (only if they are computed)
+	MD5    string `json:"md5,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	CRC32C string `json:"crc32c,omitempty"`
+}
@@ -0,0 +1,700 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package router decides where the files of the events are written and
+// transfers them. Routing is split in two steps, so the filtering logic can
+// be used without transferring any file (e.g. in dry-run mode):
+//
+//	r := router.New(cfg)
+//	err := r.LoadAttributes(ctx, event, storageClients)
+//	plan := r.Route(ctx, event)
+//	result := r.Execute(ctx, plan, storageClients)
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/events"
+	//"github.com/grycap/multi-out-faas/logging"
+	//"github.com/grycap/multi-out-faas/metrics"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/events"
+	"handler/function/logging"
+	"handler/function/metrics"
+)
+
+// Clients interface to get the storage clients of the providers by name
+type Clients interface {
+	Get(name string) (clients.StorageClient, error)
+}
+
+// StaticClients are the storage clients of the providers indexed by name,
+// e.g. to inject in-memory clients in tests
+type StaticClients map[string]clients.StorageClient
+
+// Get returns the client of a storage provider
+func (sc StaticClients) Get(name string) (clients.StorageClient, error) {
+	client, ok := sc[name]
+	if !ok {
+		return nil, errors.New("Undefined storage provider '" + name + "'")
+	}
+	return client, nil
+}
+
+// Router struct to represent the routing rules of a config
+type Router struct {
+	cfg *config.Config
+}
+
+// New returns the router of a config
+func New(cfg *config.Config) *Router {
+	return &Router{cfg: cfg}
+}
+
+// Plan struct to represent the routing decisions for an event
+type Plan struct {
+	Event *events.Event
+	// Storage providers where the source file can be downloaded from,
+	// i.e. the ones of the event source type sorted by name
+	Sources []string
+	// Outputs where the file is written (or deleted from, for removed files)
+	Outputs []OutputPlan
+	// Action applied to the source file once routed (nil if not set)
+	SourceAction *SourceActionPlan
+}
+
+// OutputPlan struct to represent the destination of a file in an output
+type OutputPlan struct {
+	StorageName string
+	Path        string
+	// Delete the file instead of writing it
+	Delete bool
+	// Maximum duration of the upload (0 means no limit)
+	Timeout time.Duration
+	// Metadata and tags written with the file
+	Metadata MetadataPlan
+	// Checksum options of the output (nil doesn't compute them)
+	Checksum *config.Checksum
+	// Error computing the path (e.g. if it escapes the output), which fails
+	// the output
+	Err error
+}
+
+// MetadataPlan struct to represent the metadata and tags written with a file
+type MetadataPlan struct {
+	// Keep the metadata and tags of the source file
+	Preserve bool
+	// Values overriding the ones of the source file (empty values are not
+	// overridden) and tags added to the file
+	Override clients.Metadata
+}
+
+// SourceActionPlan struct to represent the action applied to the source file
+type SourceActionPlan struct {
+	// config.SourceActionDelete or config.SourceActionMove
+	Action string
+	// Path of the moved file in the source storage provider
+	ArchivePath string
+	// Error computing the archive path, which fails the action
+	Err error
+}
+
+// Route evaluates the outputs for the file of an event. Removed files only
+// affect the mirrored outputs, and their source is never deleted or moved.
+// The attribute filters are checked with the attributes included in the
+// event (see LoadAttributes), and ignored for removed files
+func (r *Router) Route(ctx context.Context, event *events.Event) *Plan {
+	plan := &Plan{
+		Event:   event,
+		Sources: r.sources(event),
+	}
+
+	removed := event.EventType == events.ObjectRemoved
+	attributes := &config.Attributes{
+		Size:        event.Size,
+		ContentType: event.ContentType,
+		Metadata:    event.Metadata,
+		Tags:        event.Tags,
+	}
+	pathVars := newPathVars(ctx, event)
+	for _, output := range r.cfg.Outputs {
+		if !output.Match(event.ObjectKey) || (removed && !output.Mirror) {
+			continue
+		}
+		if !removed && !output.MatchAttributes(attributes) {
+			continue
+		}
+		dst, err := output.DestinationPath(pathVars)
+		outPlan := OutputPlan{
+			StorageName: output.StorageProviderName,
+			Path:        dst,
+			Delete:      removed,
+			Timeout:     time.Duration(output.Timeout) * time.Second,
+			Checksum:    output.Checksum,
+			Err:         err,
+		}
+		if !removed {
+			outPlan.Metadata = newMetadataPlan(&output, pathVars)
+		}
+		plan.Outputs = append(plan.Outputs, outPlan)
+	}
+
+	if len(plan.Outputs) > 0 && !removed && r.cfg.SourceAction != nil {
+		plan.SourceAction = &SourceActionPlan{Action: r.cfg.SourceAction.Action}
+		if r.cfg.SourceAction.Action == config.SourceActionMove {
+			plan.SourceAction.ArchivePath, plan.SourceAction.Err = r.cfg.SourceAction.ArchivePath(pathVars)
+		}
+	}
+	return plan
+}
+
+// LoadAttributes reads the attributes of the file of an event from its
+// source storage provider, if the outputs matching its key filter attributes
+// that the event doesn't include (e.g. the tags of the files, or the content
+// type in S3 events). Only the missing attributes are set
+func (r *Router) LoadAttributes(ctx context.Context, event *events.Event, storageClients Clients) error {
+	if event.EventType == events.ObjectRemoved {
+		return nil
+	}
+	missing := false
+	for _, output := range r.cfg.Outputs {
+		if output.FiltersAttributes() && output.Match(event.ObjectKey) && missingAttributes(&output, event) {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	err := errors.New("no storage provider of type '" + event.EventSource + "' defined")
+	for _, name := range r.sources(event) {
+		var client clients.StorageClient
+		client, err = storageClients.Get(name)
+		if err != nil {
+			continue
+		}
+		stater, ok := client.(clients.Stater)
+		if !ok {
+			err = errors.New("the storage provider '" + name + "' can't read the attributes of files")
+			continue
+		}
+		var info *clients.ObjectInfo
+		info, err = stater.Stat(ctx, event.Path)
+		if err != nil {
+			continue
+		}
+		if event.Size == 0 {
+			event.Size = info.Size
+		}
+		if event.ContentType == "" {
+			event.ContentType = info.ContentType
+		}
+		if event.ETag == "" {
+			event.ETag = info.ETag
+		}
+		if event.Metadata == nil {
+			event.Metadata = info.UserMetadata
+		}
+		if event.Tags == nil {
+			event.Tags = info.Tags
+		}
+		logging.FromContext(ctx).Debug("File attributes loaded", logging.Fields{"event_key": event.ObjectKey, "provider": name})
+		return nil
+	}
+	return errors.New("Error reading the attributes of file '" + event.ObjectKey + "': " + err.Error())
+}
+
+// missingAttributes returns true if the event doesn't include any of the
+// attributes filtered by the output. Files with size 0 are considered unknown
+func missingAttributes(output *config.Output, event *events.Event) bool {
+	switch {
+	case (output.MinSize > 0 || output.MaxSize > 0) && event.Size == 0:
+		return true
+	case len(output.ContentType) > 0 && event.ContentType == "":
+		return true
+	case len(output.Metadata) > 0 && event.Metadata == nil:
+		return true
+	case len(output.Tags) > 0 && event.Tags == nil:
+		return true
+	}
+	return false
+}
+
+// sources returns the names of the storage providers of the event source type
+func (r *Router) sources(event *events.Event) []string {
+	var names []string
+	for name, provider := range r.cfg.StorageProviders {
+		if provider.Type == event.EventSource {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Result returns the result of a plan that hasn't been executed, with the
+// StatusPlanned status in the outputs and the source action
+func (p *Plan) Result() Result {
+	result := newResult(p.Event)
+	if len(p.Outputs) == 0 {
+		result.Status = StatusUnmatched
+		return result
+	}
+	result.Status = StatusRouted
+	for _, output := range p.Outputs {
+		outResult := OutputResult{
+			StorageName: output.StorageName,
+			Path:        output.Path,
+			Status:      StatusPlanned,
+		}
+		if output.Delete {
+			outResult.Method = MethodDelete
+		}
+		if output.Err != nil {
+			outResult.Status = StatusFailed
+			outResult.Error = output.Err.Error()
+			result.Status = StatusFailed
+			result.Error = "Invalid destination path of file '" + p.Event.ObjectKey + "' in some outputs"
+		}
+		result.Outputs = append(result.Outputs, outResult)
+	}
+	if p.SourceAction != nil {
+		result.SourceAction = &SourceActionResult{
+			Action: p.SourceAction.Action,
+			Path:   p.SourceAction.ArchivePath,
+			Status: StatusPlanned,
+		}
+		if p.SourceAction.Err != nil {
+			result.SourceAction.Status = StatusFailed
+			result.SourceAction.Error = p.SourceAction.Err.Error()
+		}
+	}
+	return result
+}
+
+// Execute transfers the file of a plan to its outputs (or deletes it from
+// them, for removed files) with the given storage clients
+func (r *Router) Execute(ctx context.Context, plan *Plan, storageClients Clients) Result {
+	event := plan.Event
+	logger := logging.FromContext(ctx).With(logging.Fields{"event_key": event.ObjectKey, "source": event.EventSource})
+	ctx = logging.NewContext(ctx, logger)
+	logger.Info("Event received", logging.Fields{"event_type": event.EventType})
+
+	// If file does not match with any output skip the record
+	if len(plan.Outputs) == 0 {
+		if event.EventType == events.ObjectRemoved {
+			logger.Info("The removed file does not match any mirrored output", nil)
+		} else {
+			logger.Info("The file does not match any output", nil)
+		}
+		result := newResult(event)
+		result.Status = StatusUnmatched
+		return result
+	}
+
+	// Propagate the deletion of files to the mirrored outputs
+	if event.EventType == events.ObjectRemoved {
+		return r.propagateDeletion(ctx, plan, storageClients)
+	}
+	return r.routeFile(ctx, plan, storageClients)
+}
+
+// streamsFrom returns whether any output must be streamed from the source
+// storage provider, because it can't be copied server-side
+func streamsFrom(outputs []OutputPlan, srcName string, srcClient clients.StorageClient) bool {
+	_, copier := srcClient.(clients.Copier)
+	for _, output := range outputs {
+		if output.Err != nil {
+			continue
+		}
+		if !copier || output.StorageName != srcName || output.Checksum != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// routeFile uploads the file of an event to the outputs of the plan
+func (r *Router) routeFile(ctx context.Context, plan *Plan, storageClients Clients) Result {
+	event := plan.Event
+	logger := logging.FromContext(ctx)
+	result := newResult(event)
+
+	// Open the file from the event source storage providers, unless all the
+	// outputs are copied server-side
+	var srcClient clients.StorageClient
+	var srcName string
+	var reader io.ReadCloser
+	for _, name := range plan.Sources {
+		client, err := storageClients.Get(name)
+		if err != nil {
+			logger.Warn("Error creating client", logging.Fields{"provider": name, "error": err})
+			continue
+		}
+		if !streamsFrom(plan.Outputs, name, client) {
+			srcClient = client
+			srcName = name
+			break
+		}
+		rd, err := client.Get(ctx, event.Path)
+		if err != nil {
+			metrics.Downloads.WithLabelValues(event.EventSource, name, StatusFailed).Inc()
+			logger.Warn("Error opening file", logging.Fields{"provider": name, "error": err})
+			continue
+		}
+		metrics.Downloads.WithLabelValues(event.EventSource, name, StatusSuccess).Inc()
+		logger.Debug("File opened", logging.Fields{"provider": name})
+		srcClient = client
+		srcName = name
+		reader = rd
+		break
+	}
+
+	// Read the metadata of the source file only if it's needed
+	var srcMetadata *clients.Metadata
+	srcMetadataRead := false
+	sourceMetadata := func() *clients.Metadata {
+		if !srcMetadataRead {
+			srcMetadataRead = true
+			srcMetadata = readSourceMetadata(ctx, srcClient, event.Path)
+		}
+		return srcMetadata
+	}
+
+	// Manage upload
+	var targets []uploadTarget
+	var targetOutputs []int
+	for _, output := range plan.Outputs {
+		provName := output.StorageName
+		result.Outputs = append(result.Outputs, OutputResult{
+			StorageName: provName,
+			Path:        output.Path,
+			Status:      StatusSkipped,
+		})
+		outResult := &result.Outputs[len(result.Outputs)-1]
+		if output.Err != nil {
+			logger.Error("Invalid destination path", logging.Fields{"provider": provName, "error": output.Err})
+			outResult.Status = StatusFailed
+			outResult.Error = output.Err.Error()
+			continue
+		}
+		if srcClient == nil {
+			continue
+		}
+		// Get the client for specified output
+		client, err := storageClients.Get(provName)
+		if err != nil {
+			logger.Error("Error creating client", logging.Fields{"provider": provName, "output": outResult.Path, "error": err})
+			outResult.Status = StatusFailed
+			outResult.Error = err.Error()
+			continue
+		}
+		target := uploadTarget{
+			provider: provName,
+			path:     outResult.Path,
+			client:   client,
+			timeout:  output.Timeout,
+			retry:    r.cfg.StorageProviders[provName].Retry,
+			checksum: output.Checksum,
+			size:     event.Size,
+		}
+		// Copy the file server-side if the output is in the source provider,
+		// unless its checksums must be computed
+		outResult.Method = MethodStream
+		if copier, ok := client.(clients.Copier); ok && provName == srcName && output.Checksum == nil {
+			target.copier = copier
+			outResult.Method = MethodCopy
+		}
+		// Server-side copies keep the metadata of the source file by default
+		if target.copier == nil || !output.Metadata.isDefault() {
+			var source *clients.Metadata
+			if output.Metadata.Preserve {
+				source = sourceMetadata()
+			}
+			var dropped []string
+			target.metadata, dropped = output.Metadata.build(source)
+			if len(dropped) > 0 {
+				logger.Warn("Some tags of the source file exceed the maximum number of tags and won't be written", logging.Fields{"provider": provName, "output": outResult.Path, "tags": dropped})
+			}
+		}
+		targets = append(targets, target)
+		targetOutputs = append(targetOutputs, len(result.Outputs)-1)
+	}
+
+	if srcClient == nil {
+		result.Status = StatusFailed
+		result.Error = "The file '" + event.ObjectKey + "' cannot be downloaded from any storage provider"
+		logger.Error(result.Error, nil)
+		return result
+	}
+
+	// Upload the file to all outputs concurrently
+	reopen := func() (io.ReadCloser, error) {
+		return srcClient.Get(ctx, event.Path)
+	}
+	transferResults := transferToTargets(ctx, reader, reopen, event.Path, targets, r.cfg.Concurrency, expectedChecksums(event))
+	for i, target := range targets {
+		outResult := &result.Outputs[targetOutputs[i]]
+		outResult.Bytes = transferResults[i].bytes
+		if sums := transferResults[i].sums; sums != nil {
+			outResult.MD5 = sums.md5
+			outResult.SHA256 = sums.sha256
+			outResult.CRC32C = sums.crc32c
+		}
+		fields := logging.Fields{
+			"provider":    target.provider,
+			"output":      target.path,
+			"method":      outResult.Method,
+			"bytes":       outResult.Bytes,
+			"duration_ms": durationMillis(transferResults[i].duration),
+		}
+		if err := transferResults[i].err; err != nil {
+			fields["error"] = err
+			logger.Error("Error uploading file", fields)
+			outResult.Status = StatusFailed
+			outResult.Error = err.Error()
+		} else {
+			logger.Info("File uploaded", fields)
+			outResult.Status = StatusSuccess
+		}
+		observeTransfer(event.EventSource, outResult, transferResults[i].duration)
+	}
+
+	result.Status = StatusRouted
+	for _, outResult := range result.Outputs {
+		if outResult.Status == StatusFailed {
+			result.Status = StatusFailed
+			result.Error = "Error uploading file '" + event.ObjectKey + "' to some outputs"
+		}
+	}
+
+	// Delete or move the source file only if all the uploads succeeded and
+	// none of them overwrote it
+	if result.Status == StatusRouted && plan.SourceAction != nil {
+		if output := sourceOutput(result.Outputs, srcName, event.Path); output != nil {
+			result.SourceAction = &SourceActionResult{
+				Action: plan.SourceAction.Action,
+				Path:   plan.SourceAction.ArchivePath,
+				Status: StatusSkipped,
+				Error:  "The source file was overwritten by the output '" + output.Path + "' in the storage provider '" + output.StorageName + "'",
+			}
+			logger.Warn("Source action skipped", logging.Fields{"action": plan.SourceAction.Action, "provider": srcName, "error": result.SourceAction.Error})
+			return result
+		}
+		result.SourceAction = applySourceAction(ctx, plan.SourceAction, event.Path, srcClient, r.cfg.StorageProviders[srcName].Retry)
+		if result.SourceAction.Status == StatusFailed {
+			result.Status = StatusFailed
+			result.Error = "Error applying the " + result.SourceAction.Action + " action to the source file '" + event.ObjectKey + "'"
+			logger.Error("Error applying the source action", logging.Fields{"action": result.SourceAction.Action, "provider": srcName, "error": result.SourceAction.Error})
+		} else {
+			logger.Info("Source action applied", logging.Fields{"action": result.SourceAction.Action, "provider": srcName, "output": result.SourceAction.Path})
+		}
+	}
+	return result
+}
+
+// propagateDeletion deletes a removed file from the mirrored outputs of the plan
+func (r *Router) propagateDeletion(ctx context.Context, plan *Plan, storageClients Clients) Result {
+	event := plan.Event
+	logger := logging.FromContext(ctx)
+	result := newResult(event)
+	result.Status = StatusRouted
+	for _, output := range plan.Outputs {
+		outResult := OutputResult{
+			StorageName: output.StorageName,
+			Path:        output.Path,
+			Method:      MethodDelete,
+			Status:      StatusSuccess,
+		}
+		start := time.Now()
+		client, err := storageClients.Get(output.StorageName)
+		if err == nil {
+			err = output.Err
+		}
+		if err == nil {
+			err = client.Delete(ctx, outResult.Path)
+		}
+		// Delete also the checksum sidecar file
+		if err == nil && output.Checksum != nil && output.Checksum.Store == config.ChecksumSidecar {
+			if sidecarErr := client.Delete(ctx, outResult.Path+sidecarExtension); sidecarErr != nil {
+				logger.Warn("Error deleting the checksum file", logging.Fields{"provider": outResult.StorageName, "output": outResult.Path + sidecarExtension, "error": sidecarErr})
+			}
+		}
+		duration := time.Since(start)
+		fields := logging.Fields{
+			"provider":    outResult.StorageName,
+			"output":      outResult.Path,
+			"duration_ms": durationMillis(duration),
+		}
+		if err != nil {
+			fields["error"] = err
+			logger.Error("Error deleting file", fields)
+			outResult.Status = StatusFailed
+			outResult.Error = err.Error()
+			result.Status = StatusFailed
+			result.Error = "Error deleting file '" + event.ObjectKey + "' from some outputs"
+		} else {
+			logger.Info("File deleted", fields)
+		}
+		observeTransfer(event.EventSource, &outResult, duration)
+		result.Outputs = append(result.Outputs, outResult)
+	}
+	return result
+}
+
+// newMetadataPlan returns the metadata options of an output
+func newMetadataPlan(output *config.Output, pathVars *config.PathVars) MetadataPlan {
+	m := MetadataPlan{Preserve: output.PreserveMetadata()}
+	if output.WriteMetadata != nil {
+		m.Override.ContentType = output.WriteMetadata.ContentType
+		m.Override.ContentEncoding = output.WriteMetadata.ContentEncoding
+		m.Override.CacheControl = output.WriteMetadata.CacheControl
+	}
+	m.Override.UserMetadata = output.UserMetadata()
+	m.Override.Tags = output.ExtraTags(pathVars)
+	return m
+}
+
+// isDefault returns true if the source metadata is kept without changes
+func (m *MetadataPlan) isDefault() bool {
+	o := &m.Override
+	return m.Preserve && o.ContentType == "" && o.ContentEncoding == "" && o.CacheControl == "" &&
+		len(o.UserMetadata) == 0 && len(o.Tags) == 0
+}
+
+// build returns the metadata written with the file, applying the overrides
+// to the metadata of the source file (nil if it's not preserved or unknown).
+// The tags of the output are always written, and the source tags exceeding
+// the maximum number of tags once they are added are discarded, returning
+// their keys
+func (m *MetadataPlan) build(source *clients.Metadata) (*clients.Metadata, []string) {
+	metadata := &clients.Metadata{
+		UserMetadata: make(map[string]string),
+		Tags:         make(map[string]string),
+	}
+	for _, values := range []*clients.Metadata{source, &m.Override} {
+		if values == nil {
+			continue
+		}
+		if values.ContentType != "" {
+			metadata.ContentType = values.ContentType
+		}
+		if values.ContentEncoding != "" {
+			metadata.ContentEncoding = values.ContentEncoding
+		}
+		if values.CacheControl != "" {
+			metadata.CacheControl = values.CacheControl
+		}
+		for key, value := range values.UserMetadata {
+			metadata.UserMetadata[key] = value
+		}
+	}
+
+	for key, value := range m.Override.Tags {
+		metadata.Tags[key] = value
+	}
+	var dropped []string
+	if source != nil {
+		keys := make([]string, 0, len(source.Tags))
+		for key := range source.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, ok := metadata.Tags[key]; ok {
+				continue
+			}
+			if len(metadata.Tags) >= config.MaxTags {
+				dropped = append(dropped, key)
+				continue
+			}
+			metadata.Tags[key] = source.Tags[key]
+		}
+	}
+	return metadata, dropped
+}
+
+// readSourceMetadata returns the metadata of the source file, or nil if the
+// source storage provider can't read it
+func readSourceMetadata(ctx context.Context, srcClient clients.StorageClient, srcPath string) *clients.Metadata {
+	stater, ok := srcClient.(clients.Stater)
+	if !ok {
+		return nil
+	}
+	info, err := stater.Stat(ctx, srcPath)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Error reading the metadata of the source file, it won't be preserved", logging.Fields{"error": err})
+		return nil
+	}
+	return &info.Metadata
+}
+
+func newResult(event *events.Event) Result {
+	return Result{
+		EventKey: event.ObjectKey,
+		Source:   event.EventSource,
+		Outputs:  []OutputResult{},
+	}
+}
+
+// FailedResult returns the result of an event that failed before routing it
+func FailedResult(event *events.Event, msg string) Result {
+	result := newResult(event)
+	result.Status = StatusFailed
+	result.Error = msg
+	return result
+}
+
+// observeTransfer updates the metrics of the operations on the outputs
+func observeTransfer(source string, outResult *OutputResult, duration time.Duration) {
+	metrics.Transfers.WithLabelValues(source, outResult.StorageName, outResult.Method, outResult.Status).Inc()
+	metrics.TransferDuration.WithLabelValues(source, outResult.StorageName, outResult.Method, outResult.Status).Observe(duration.Seconds())
+	if outResult.Bytes > 0 {
+		metrics.TransferredBytes.WithLabelValues(source, outResult.StorageName).Add(float64(outResult.Bytes))
+	}
+}
+
+// durationMillis returns a duration in milliseconds for the log entries
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newPathVars returns the values of the output path template variables for an event.
+// If the event time is invalid, the current time is used
+func newPathVars(ctx context.Context, event *events.Event) *config.PathVars {
+	eventTime, err := event.Time()
+	if err != nil {
+		logging.FromContext(ctx).Warn("Invalid event time, using the current time", logging.Fields{"error": err})
+		eventTime = time.Now().UTC()
+	}
+	return &config.PathVars{
+		Key:       event.ObjectKey,
+		Bucket:    event.Bucket,
+		Source:    event.EventSource,
+		EventTime: eventTime,
+	}
+}
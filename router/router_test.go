@@ -0,0 +1,511 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/events"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/events"
+)
+
+const testConfig = `{
+	"storages": {
+		"local": [
+			{"name": "input", "directory": "/input"},
+			{"name": "output", "directory": "/output"}
+		]
+	},
+	"output": [
+		{"storage_name": "output", "path": "videos/{key}", "suffix": [".avi"], "mirror": true},
+		{"storage_name": "input", "path": "copies", "suffix": [".avi"]}
+	]
+}`
+
+// testMoveConfig moves the source files, so its outputs can't be mirrored
+const testMoveConfig = `{
+	"storages": {
+		"local": [
+			{"name": "input", "directory": "/input"},
+			{"name": "output", "directory": "/output"}
+		]
+	},
+	"output": [
+		{"storage_name": "output", "path": "videos/{key}", "suffix": [".avi"]},
+		{"storage_name": "input", "path": "copies", "suffix": [".avi"]}
+	],
+	"source_action": {"action": "move", "path": "archive/{key}"}
+}`
+
+func newTestRouter(t *testing.T, testConfig string) *Router {
+	cfg, err := config.ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(cfg)
+}
+
+func newTestEvent(key, eventType string) *events.Event {
+	return &events.Event{
+		Path:        key,
+		ObjectKey:   key,
+		EventTime:   "2019-11-05T10:00:00Z",
+		EventSource: "local",
+		EventType:   eventType,
+	}
+}
+
+func TestRoute(t *testing.T) {
+	r := newTestRouter(t, testMoveConfig)
+
+	plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated))
+	expectedOutputs := []OutputPlan{
+		{StorageName: "output", Path: "videos/in/video.avi", Metadata: MetadataPlan{Preserve: true}},
+		{StorageName: "input", Path: "copies/video.avi", Metadata: MetadataPlan{Preserve: true}},
+	}
+	if !reflect.DeepEqual(plan.Sources, []string{"input", "output"}) {
+		t.Errorf("Error routing event. Expected sources: %v. Received: %v", []string{"input", "output"}, plan.Sources)
+	}
+	if !reflect.DeepEqual(plan.Outputs, expectedOutputs) {
+		t.Errorf("Error routing event. Expected outputs: %+v. Received: %+v", expectedOutputs, plan.Outputs)
+	}
+	expectedAction := &SourceActionPlan{Action: config.SourceActionMove, ArchivePath: "archive/in/video.avi"}
+	if !reflect.DeepEqual(plan.SourceAction, expectedAction) {
+		t.Errorf("Error routing event. Expected source action: %+v. Received: %+v", expectedAction, plan.SourceAction)
+	}
+
+	// Removed files are only deleted from the mirrored outputs
+	r = newTestRouter(t, testConfig)
+	plan = r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectRemoved))
+	expectedOutputs = []OutputPlan{{StorageName: "output", Path: "videos/in/video.avi", Delete: true}}
+	if !reflect.DeepEqual(plan.Outputs, expectedOutputs) || plan.SourceAction != nil {
+		t.Errorf("Error routing removed event. Received: %+v", plan)
+	}
+
+	// Unmatched
+	plan = r.Route(context.Background(), newTestEvent("in/video.mp4", events.ObjectCreated))
+	if len(plan.Outputs) != 0 || plan.SourceAction != nil {
+		t.Errorf("Error routing unmatched event. Received: %+v", plan)
+	}
+	if result := plan.Result(); result.Status != StatusUnmatched {
+		t.Errorf("Error getting result of unmatched plan. Received: %+v", result)
+	}
+}
+
+func TestPlanResult(t *testing.T) {
+	r := newTestRouter(t, testMoveConfig)
+	result := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated)).Result()
+	expected := Result{
+		EventKey: "in/video.avi",
+		Source:   "local",
+		Status:   StatusRouted,
+		Outputs: []OutputResult{
+			{StorageName: "output", Path: "videos/in/video.avi", Status: StatusPlanned},
+			{StorageName: "input", Path: "copies/video.avi", Status: StatusPlanned},
+		},
+		SourceAction: &SourceActionResult{Action: config.SourceActionMove, Path: "archive/in/video.avi", Status: StatusPlanned},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Error getting plan result. Expected: %+v. Received: %+v", expected, result)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	r := newTestRouter(t, testMoveConfig)
+	input := newFakeClient()
+	output := newFakeClient()
+	input.files["in/video.avi"] = "content"
+	storageClients := StaticClients{"input": input, "output": output}
+
+	plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated))
+	result := r.Execute(context.Background(), plan, storageClients)
+	if result.Status != StatusRouted || len(result.Outputs) != 2 {
+		t.Fatalf("Error executing plan. Received: %+v", result)
+	}
+	// The output in the source provider is copied server-side
+	if result.Outputs[0].Method != MethodStream || result.Outputs[1].Method != MethodCopy {
+		t.Errorf("Error executing plan. Wrong methods: %+v", result.Outputs)
+	}
+	if output.files["videos/in/video.avi"] != "content" || input.files["copies/video.avi"] != "content" {
+		t.Errorf("Error executing plan. Files not uploaded: %v %v", input.files, output.files)
+	}
+	if result.SourceAction == nil || result.SourceAction.Status != StatusSuccess || input.files["archive/in/video.avi"] != "content" {
+		t.Errorf("Error executing plan. Source file not moved: %+v %v", result.SourceAction, input.files)
+	}
+	if _, ok := input.files["in/video.avi"]; ok {
+		t.Error("Error executing plan. Source file not removed")
+	}
+
+	// Deletions are propagated to the mirrored outputs
+	r = newTestRouter(t, testConfig)
+	plan = r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectRemoved))
+	result = r.Execute(context.Background(), plan, storageClients)
+	if result.Status != StatusRouted || len(result.Outputs) != 1 || result.Outputs[0].Method != MethodDelete {
+		t.Errorf("Error executing removal plan. Received: %+v", result)
+	}
+	if _, ok := output.files["videos/in/video.avi"]; ok || input.files["copies/video.avi"] != "content" {
+		t.Errorf("Error executing removal plan. Wrong files: %v %v", input.files, output.files)
+	}
+}
+
+func TestExecuteMissingSource(t *testing.T) {
+	r := newTestRouter(t, testMoveConfig)
+	storageClients := StaticClients{"input": newFakeClient(), "output": newFakeClient()}
+
+	plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated))
+	result := r.Execute(context.Background(), plan, storageClients)
+	if result.Status != StatusFailed || result.SourceAction != nil {
+		t.Errorf("Error executing plan without source file. Received: %+v", result)
+	}
+	for _, output := range result.Outputs {
+		if output.Status != StatusSkipped {
+			t.Errorf("Error executing plan without source file. Output not skipped: %+v", output)
+		}
+	}
+
+	// Undefined clients fail the outputs
+	input := newFakeClient()
+	input.files["in/video.avi"] = "content"
+	result = r.Execute(context.Background(), plan, StaticClients{"input": input})
+	if result.Status != StatusFailed || result.Outputs[0].Status != StatusFailed || result.Outputs[1].Status != StatusSuccess {
+		t.Errorf("Error executing plan with undefined client. Received: %+v", result)
+	}
+}
+
+func TestExecuteCopiesOnly(t *testing.T) {
+	r := newTestRouter(t, `{
+		"storages": {"local": [{"name": "input", "directory": "/input"}]},
+		"output": [
+			{"storage_name": "input", "path": "copies"},
+			{"storage_name": "input", "path": "backups"}
+		]
+	}`)
+	input := newFakeClient()
+	input.files["in/video.avi"] = "content"
+
+	plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated))
+	result := r.Execute(context.Background(), plan, StaticClients{"input": input})
+	if result.Status != StatusRouted || input.files["copies/video.avi"] != "content" || input.files["backups/video.avi"] != "content" {
+		t.Errorf("Error executing plan with server-side copies. Received: %+v", result)
+	}
+	// The source file isn't downloaded if all the outputs are copied
+	if input.gets != 0 {
+		t.Errorf("Error executing plan with server-side copies. Source file downloaded %d times", input.gets)
+	}
+}
+
+// statClient struct to represent an in-memory storage client able to read
+// the attributes of files
+type statClient struct {
+	*fakeClient
+	info  *clients.ObjectInfo
+	stats int
+}
+
+func (sc *statClient) Stat(ctx context.Context, path string) (*clients.ObjectInfo, error) {
+	sc.stats++
+	if _, ok := sc.files[path]; !ok {
+		return nil, errors.New("File not found")
+	}
+	return sc.info, nil
+}
+
+func TestLoadAttributes(t *testing.T) {
+	cfg, err := config.ReadConfig(strings.NewReader(`{
+		"storages": {"local": [{"name": "input", "directory": "/input"}]},
+		"output": [
+			{"storage_name": "input", "path": "raw", "metadata": {"quality": "raw"}, "mirror": true},
+			{"storage_name": "input", "path": "large", "min_size": 1024},
+			{"storage_name": "input", "path": "tagged", "suffix": [".jpg"], "tags": {"project": "grycap"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(cfg)
+	input := &statClient{
+		fakeClient: newFakeClient(),
+		info: &clients.ObjectInfo{
+			Size: 2048,
+			Metadata: clients.Metadata{
+				UserMetadata: map[string]string{"quality": "raw"},
+				Tags:         map[string]string{"project": "grycap"},
+			},
+		},
+	}
+	input.files["in/video.avi"] = "content"
+	storageClients := StaticClients{"input": input}
+
+	// Only the missing attributes are loaded
+	event := newTestEvent("in/video.avi", events.ObjectCreated)
+	event.Size = 10
+	if err := r.LoadAttributes(context.Background(), event, storageClients); err != nil {
+		t.Fatal(err)
+	}
+	if input.stats != 1 || event.Size != 10 || event.Metadata["quality"] != "raw" || event.Tags["project"] != "grycap" {
+		t.Errorf("Error loading attributes: %+v", event)
+	}
+	plan := r.Route(context.Background(), event)
+	if len(plan.Outputs) != 1 || plan.Outputs[0].Path != "raw/video.avi" {
+		t.Errorf("Error routing event with attributes. Received: %+v", plan.Outputs)
+	}
+
+	// Events including the filtered attributes don't need to be loaded
+	event = newTestEvent("in/video.avi", events.ObjectCreated)
+	event.Size = 10
+	event.Metadata = map[string]string{}
+	if err := r.LoadAttributes(context.Background(), event, storageClients); err != nil || input.stats != 1 {
+		t.Errorf("Error skipping attributes load: %v", err)
+	}
+	if plan := r.Route(context.Background(), event); len(plan.Outputs) != 0 {
+		t.Errorf("Error routing event with attributes. Received: %+v", plan.Outputs)
+	}
+
+	// The outputs filtering attributes don't match if they can't be loaded
+	event = newTestEvent("in/missing.avi", events.ObjectCreated)
+	if err := r.LoadAttributes(context.Background(), event, storageClients); err == nil {
+		t.Error("Error loading attributes of missing file")
+	}
+	if plan := r.Route(context.Background(), event); len(plan.Outputs) != 0 {
+		t.Errorf("Error routing event without attributes. Received: %+v", plan.Outputs)
+	}
+
+	// The attribute filters are ignored for removed files
+	if plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectRemoved)); len(plan.Outputs) != 1 || !plan.Outputs[0].Delete {
+		t.Errorf("Error routing removed event. Received: %+v", plan.Outputs)
+	}
+}
+
+func TestExecuteMetadata(t *testing.T) {
+	cfg, err := config.ReadConfig(strings.NewReader(`{
+		"storages": {"local": [{"name": "input", "directory": "/input"}, {"name": "output", "directory": "/output"}]},
+		"output": [
+			{"storage_name": "output", "path": "preserved"},
+			{"storage_name": "output", "path": "dropped", "write_metadata": {
+				"mode": "drop",
+				"cache_control": "no-cache",
+				"tags": {"routed-by": "multi-out-faas", "source-key": "{key}"}
+			}},
+			{"storage_name": "input", "path": "copies"},
+			{"storage_name": "input", "path": "overridden", "write_metadata": {"user_metadata": {"X-Amz-Meta-Quality": "low"}}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := &statClient{
+		fakeClient: newFakeClient(),
+		info: &clients.ObjectInfo{
+			Metadata: clients.Metadata{
+				ContentType:  "video/x-msvideo",
+				UserMetadata: map[string]string{"quality": "raw", "camera": "1"},
+				Tags:         map[string]string{"project": "grycap"},
+			},
+		},
+	}
+	input.files["in/video.avi"] = "content"
+	output := newFakeClient()
+
+	r := New(cfg)
+	plan := r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated))
+	result := r.Execute(context.Background(), plan, StaticClients{"input": input, "output": output})
+	if result.Status != StatusRouted {
+		t.Fatalf("Error executing plan. Received: %+v", result)
+	}
+
+	expected := map[string]*clients.Metadata{
+		"preserved/video.avi": &input.info.Metadata,
+		"dropped/video.avi": {
+			CacheControl: "no-cache",
+			UserMetadata: map[string]string{},
+			Tags:         map[string]string{"routed-by": "multi-out-faas", "source-key": "in/video.avi"},
+		},
+	}
+	for path, metadata := range expected {
+		if !reflect.DeepEqual(output.metadata[path], metadata) {
+			t.Errorf("Error writing metadata of '%s'. Expected: %+v. Received: %+v", path, metadata, output.metadata[path])
+		}
+	}
+	// Server-side copies keep the source metadata unless it's overridden
+	if input.metadata["copies/video.avi"] != nil {
+		t.Errorf("Error copying file keeping its metadata. Received: %+v", input.metadata["copies/video.avi"])
+	}
+	overridden := input.metadata["overridden/video.avi"]
+	if overridden == nil || overridden.ContentType != "video/x-msvideo" || overridden.UserMetadata["quality"] != "low" || overridden.UserMetadata["camera"] != "1" {
+		t.Errorf("Error copying file overriding its metadata. Received: %+v", overridden)
+	}
+}
+
+func TestExecuteChecksum(t *testing.T) {
+	cfg, err := config.ReadConfig(strings.NewReader(`{
+		"storages": {"local": [{"name": "input", "directory": "/input"}, {"name": "output", "directory": "/output"}]},
+		"output": [
+			{"storage_name": "input", "path": "sidecar", "mirror": true, "checksum": {"store": "sidecar"}},
+			{"storage_name": "output", "path": "verified", "checksum": {"verify": true}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := newFakeClient()
+	input.files["in/file.txt"] = "content"
+	output := newFakeClient()
+	storageClients := StaticClients{"input": input, "output": output}
+
+	r := New(cfg)
+	event := newTestEvent("in/file.txt", events.ObjectCreated)
+	event.ETag = "d41d8cd98f00b204e9800998ecf8427e"
+	result := r.Execute(context.Background(), r.Route(context.Background(), event), storageClients)
+
+	// Outputs with checksums are streamed even if they can be copied
+	sidecar := result.Outputs[0]
+	if sidecar.Status != StatusSuccess || sidecar.Method != MethodStream || sidecar.SHA256 != contentSHA256 || sidecar.MD5 != contentMD5 {
+		t.Errorf("Error uploading file with checksums. Received: %+v", sidecar)
+	}
+	// The checksums aren't stored if they don't match
+	if _, ok := input.files["sidecar/file.txt.sha256"]; ok {
+		t.Error("Error storing checksum sidecar file of file with checksum mismatch")
+	}
+	verified := result.Outputs[1]
+	if result.Status != StatusFailed || verified.Status != StatusFailed || !strings.Contains(verified.Error, "Checksum mismatch") {
+		t.Errorf("Error reporting checksum mismatch. Received: %+v", result)
+	}
+	if _, ok := output.files["verified/file.txt"]; ok {
+		t.Error("Error uploading file with checksum mismatch")
+	}
+
+	event.ETag = contentMD5
+	result = r.Execute(context.Background(), r.Route(context.Background(), event), storageClients)
+	if result.Status != StatusRouted || output.files["verified/file.txt"] != "content" {
+		t.Errorf("Error uploading file with valid checksum. Received: %+v", result)
+	}
+	if input.files["sidecar/file.txt.sha256"] != contentSHA256+"  file.txt\n" {
+		t.Error("Error storing checksum sidecar file")
+	}
+
+	// Mirrored deletions remove the sidecar files
+	r.Execute(context.Background(), r.Route(context.Background(), newTestEvent("in/file.txt", events.ObjectRemoved)), storageClients)
+	if _, ok := input.files["sidecar/file.txt.sha256"]; ok {
+		t.Error("Error deleting checksum sidecar file")
+	}
+}
+
+func TestExecutePathTraversal(t *testing.T) {
+	cfg, err := config.ReadConfig(strings.NewReader(`{
+		"storages": {"local": [{"name": "input", "directory": "/input"}, {"name": "output", "directory": "/output"}]},
+		"output": [
+			{"storage_name": "output", "path": "out-bucket/{key}"},
+			{"storage_name": "output", "path": "safe-bucket"}
+		],
+		"source_action": {"action": "move", "path": "archive"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := newFakeClient()
+	input.files["../../victim-bucket/evil.txt"] = "content"
+	output := newFakeClient()
+
+	r := New(cfg)
+	plan := r.Route(context.Background(), newTestEvent("../../victim-bucket/evil.txt", events.ObjectCreated))
+	if planned := plan.Result(); planned.Status != StatusFailed || planned.Outputs[0].Status != StatusFailed || planned.SourceAction.Status != StatusFailed {
+		t.Errorf("Error reporting invalid paths in the plan. Received: %+v", planned)
+	}
+
+	result := r.Execute(context.Background(), plan, StaticClients{"input": input, "output": output})
+	if result.Status != StatusFailed || result.Outputs[0].Status != StatusFailed || !strings.Contains(result.Outputs[0].Error, "'..' segments") {
+		t.Errorf("Error failing output with path outside the bucket. Received: %+v", result)
+	}
+	if result.Outputs[1].Status != StatusSuccess || output.files["safe-bucket/evil.txt"] != "content" {
+		t.Errorf("Error uploading file to valid output. Received: %+v", result.Outputs[1])
+	}
+	for path := range output.files {
+		if strings.HasPrefix(path, "victim-bucket") {
+			t.Errorf("Error writing file outside the output bucket: '%s'", path)
+		}
+	}
+	if _, ok := input.files["../../victim-bucket/evil.txt"]; !ok || result.SourceAction != nil {
+		t.Error("Error keeping the source file of a failed record")
+	}
+}
+
+func TestExecuteSourceOverwritten(t *testing.T) {
+	cfg, err := config.ReadConfig(strings.NewReader(`{
+		"storages": {"local": [{"name": "input", "directory": "/input"}, {"name": "output", "directory": "/output"}]},
+		"output": [
+			{"storage_name": "output", "path": "videos"},
+			{"storage_name": "input", "path": "{key}"}
+		],
+		"source_action": {"action": "delete"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := newFakeClient()
+	input.files["in/video.avi"] = "content"
+	// The file is streamed to the output in the source provider
+	storageClients := StaticClients{"input": struct{ clients.StorageClient }{input}, "output": newFakeClient()}
+
+	r := New(cfg)
+	result := r.Execute(context.Background(), r.Route(context.Background(), newTestEvent("in/video.avi", events.ObjectCreated)), storageClients)
+	if result.Status != StatusRouted || result.Outputs[1].Status != StatusSuccess {
+		t.Fatalf("Error executing plan. Received: %+v", result)
+	}
+	if result.SourceAction == nil || result.SourceAction.Status != StatusSkipped || result.SourceAction.Error == "" {
+		t.Errorf("Error skipping the action of an overwritten source file. Received: %+v", result.SourceAction)
+	}
+	if input.files["in/video.avi"] != "content" {
+		t.Error("Error keeping the source file overwritten by an output")
+	}
+}
+
+func TestMetadataPlanTagLimit(t *testing.T) {
+	source := &clients.Metadata{Tags: map[string]string{}}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		source.Tags[key] = "source"
+	}
+	m := MetadataPlan{
+		Preserve: true,
+		Override: clients.Metadata{Tags: map[string]string{"b": "output", "routed-by": "multi-out-faas"}},
+	}
+
+	// The tags of the output are kept, discarding the last source tags
+	metadata, dropped := m.build(source)
+	if len(metadata.Tags) != config.MaxTags || metadata.Tags["b"] != "output" || metadata.Tags["routed-by"] != "multi-out-faas" || metadata.Tags["a"] != "source" {
+		t.Errorf("Error limiting the number of tags. Received: %v", metadata.Tags)
+	}
+	if !reflect.DeepEqual(dropped, []string{"j"}) {
+		t.Errorf("Error returning the discarded tags. Expected: %v. Received: %v", []string{"j"}, dropped)
+	}
+
+	// Within the limit
+	delete(source.Tags, "j")
+	if metadata, dropped := m.build(source); len(metadata.Tags) != config.MaxTags || dropped != nil {
+		t.Errorf("Error merging the tags. Received: %v, discarded %v", metadata.Tags, dropped)
+	}
+}
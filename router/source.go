@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/clients"
+	"handler/function/config"
+)
+
+// applySourceAction deletes the source file of a routed event or moves it to
+// the archive path of its storage provider. The file is never deleted if it
+// couldn't be archived
+func applySourceAction(ctx context.Context, action *SourceActionPlan, srcPath string, srcClient clients.StorageClient, retry config.RetryPolicy) *SourceActionResult {
+	result := &SourceActionResult{
+		Action: action.Action,
+		Path:   action.ArchivePath,
+		Status: StatusSuccess,
+	}
+
+	if action.Err != nil {
+		result.Status = StatusFailed
+		result.Error = action.Err.Error()
+		return result
+	}
+	if action.Action == config.SourceActionMove {
+		if err := archiveSource(ctx, srcPath, action.ArchivePath, srcClient, retry); err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	if err := srcClient.Delete(ctx, srcPath); err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// sourceOutput returns the successful output whose destination is the source
+// file itself, or nil if none of them overwrote it
+func sourceOutput(outputs []OutputResult, srcName, srcPath string) *OutputResult {
+	for i := range outputs {
+		output := &outputs[i]
+		if output.Status == StatusSuccess && output.StorageName == srcName && strings.Trim(output.Path, "/") == strings.Trim(srcPath, "/") {
+			return output
+		}
+	}
+	return nil
+}
+
+// archiveSource copies the source file to the archive path, server-side if
+// the source storage provider supports it (keeping its metadata)
+func archiveSource(ctx context.Context, srcPath, archivePath string, srcClient clients.StorageClient, retry config.RetryPolicy) error {
+	if strings.Trim(archivePath, "/") == strings.Trim(srcPath, "/") {
+		return errors.New("the archive path is the same as the source path")
+	}
+	if copier, ok := srcClient.(clients.Copier); ok {
+		return copier.Copy(ctx, srcPath, archivePath, nil)
+	}
+	return clients.Retry(ctx, &retry, func(attempt int) error {
+		reader, err := srcClient.Get(ctx, srcPath)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return srcClient.Put(ctx, reader, archivePath, nil)
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/clients"
+	"handler/function/config"
+)
+
+func TestApplySourceAction(t *testing.T) {
+	deleteAction := &SourceActionPlan{Action: config.SourceActionDelete}
+	moveAction := &SourceActionPlan{Action: config.SourceActionMove, ArchivePath: "input/archive/videos/video.avi"}
+
+	// Delete
+	client := newFakeClient()
+	client.files["input/videos/video.avi"] = "content"
+	result := applySourceAction(context.Background(), deleteAction, "input/videos/video.avi", client, config.RetryPolicy{})
+	if result.Status != StatusSuccess || len(client.files) != 0 {
+		t.Errorf("Error deleting source file: %+v", result)
+	}
+
+	// Move server-side
+	client = newFakeClient()
+	client.files["input/videos/video.avi"] = "content"
+	result = applySourceAction(context.Background(), moveAction, "input/videos/video.avi", client, config.RetryPolicy{})
+	if result.Status != StatusSuccess || result.Path != "input/archive/videos/video.avi" {
+		t.Errorf("Error moving source file: %+v", result)
+	}
+	if _, ok := client.files["input/videos/video.avi"]; ok || client.files["input/archive/videos/video.avi"] != "content" {
+		t.Errorf("Error moving source file: %v", client.files)
+	}
+
+	// Move streaming the file, without deleting it if the upload fails
+	client = newFakeClient()
+	client.files["input/videos/video.avi"] = "content"
+	client.putErr = errors.New("upload error")
+	streamClient := struct{ clients.StorageClient }{client}
+	result = applySourceAction(context.Background(), moveAction, "input/videos/video.avi", streamClient, config.RetryPolicy{})
+	if result.Status != StatusFailed || result.Error != "upload error" {
+		t.Errorf("Error reporting failed move: %+v", result)
+	}
+	if client.files["input/videos/video.avi"] != "content" {
+		t.Error("Error keeping source file after failed move")
+	}
+
+	// Never delete the file if the archive path is the source path
+	client = newFakeClient()
+	client.files["input/videos/video.avi"] = "content"
+	selfMove := &SourceActionPlan{Action: config.SourceActionMove, ArchivePath: "input/videos/video.avi"}
+	result = applySourceAction(context.Background(), selfMove, "input/videos/video.avi", client, config.RetryPolicy{})
+	if result.Status != StatusFailed || client.files["input/videos/video.avi"] != "content" {
+		t.Errorf("Error keeping source file moved to itself: %+v", result)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/logging"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/logging"
+)
+
+var (
+	errUploadFinished   = errors.New("Upload finished")
+	errAllUploadsFailed = errors.New("All uploads failed")
+)
+
+// uploadTarget struct to represent the destination of a file
+type uploadTarget struct {
+	provider string
+	path     string
+	client   clients.StorageClient
+	// copier is set when the file can be copied server-side from the source
+	copier  clients.Copier
+	timeout time.Duration
+	// retry policy of the target storage provider for failed uploads
+	retry config.RetryPolicy
+	// metadata and tags written with the file
+	metadata *clients.Metadata
+	// checksum options of the output (nil doesn't compute them)
+	checksum *config.Checksum
+	// size of the file in bytes (0 if unknown)
+	size int64
+}
+
+// transferResult struct to represent the outcome of the upload to a target
+type transferResult struct {
+	bytes int64
+	err   error
+	// duration of the upload, including its retries
+	duration time.Duration
+	// checksums of the uploaded file (nil if they aren't computed)
+	sums *checksums
+}
+
+// countingReader counts the bytes read from the underlying reader, and
+// reports the expected size of the file to the clients (see clients.Sizer)
+type countingReader struct {
+	reader io.Reader
+	count  int64
+	size   int64
+}
+
+func (cr *countingReader) Size() int64 {
+	return cr.size
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+// context returns the context for the upload, applying the target timeout
+func (t *uploadTarget) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if t.timeout > 0 {
+		return context.WithTimeout(parent, t.timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// fanoutWriter writes to several pipes at the same time, discarding the
+// pipes whose reader has been closed (i.e. the upload has failed)
+type fanoutWriter struct {
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+func (fw *fanoutWriter) Write(p []byte) (int, error) {
+	active := 0
+	for i, w := range fw.writers {
+		if fw.failed[i] {
+			continue
+		}
+		if _, err := w.Write(p); err != nil {
+			fw.failed[i] = true
+			continue
+		}
+		active++
+	}
+	if active == 0 {
+		return 0, errAllUploadsFailed
+	}
+	return len(p), nil
+}
+
+// transferToTargets uploads the file in srcPath to all targets, running at
+// most concurrency uploads at the same time (0 means no limit).
+// Each upload frees its slot as soon as it finishes. The targets that can't
+// be copied server-side are started in groups that share a single read of
+// the source, as many as free slots, and the source is reopened for each
+// group. The reader passed (nil if the source isn't opened yet) is used by
+// the first group and is always closed.
+// The streamed files are verified with the expected checksums of the source.
+// Returns the result of the upload to each target
+func transferToTargets(ctx context.Context, reader io.ReadCloser, reopen func() (io.ReadCloser, error), srcPath string, targets []uploadTarget, concurrency int, expected *checksums) []transferResult {
+	results := make([]transferResult, len(targets))
+	defer func() {
+		if reader != nil {
+			reader.Close()
+		}
+	}()
+
+	if concurrency <= 0 {
+		concurrency = len(targets)
+	}
+	slots := make(chan struct{}, concurrency)
+	release := func() { <-slots }
+
+	var wg sync.WaitGroup
+	started := make([]bool, len(targets))
+	for i := range targets {
+		if started[i] {
+			continue
+		}
+		slots <- struct{}{}
+		started[i] = true
+		wg.Add(1)
+
+		if targets[i].copier != nil {
+			go func(i int) {
+				defer wg.Done()
+				defer release()
+				target := targets[i]
+				copyCtx, cancel := target.context(ctx)
+				defer cancel()
+				start := time.Now()
+				results[i].err = target.copier.Copy(copyCtx, srcPath, target.path, target.metadata)
+				results[i].duration = time.Since(start)
+			}(i)
+			continue
+		}
+
+		// Stream the file to the next targets that have a free slot
+		group := []int{i}
+	next:
+		for j := i + 1; j < len(targets); j++ {
+			if started[j] || targets[j].copier != nil {
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+				started[j] = true
+				group = append(group, j)
+			default:
+				break next
+			}
+		}
+		go func(group []int, reader io.ReadCloser) {
+			defer wg.Done()
+			var err error
+			if reader == nil {
+				reader, err = reopen()
+			}
+			if err != nil {
+				for _, i := range group {
+					results[i].err = err
+				}
+			} else {
+				batch := make([]uploadTarget, len(group))
+				for j, i := range group {
+					batch[j] = targets[i]
+				}
+				for j, result := range streamToTargets(ctx, reader, batch, expected) {
+					results[group[j]] = result
+				}
+				reader.Close()
+			}
+
+			// Retry the failed uploads, reopening the source for each one
+			for _, i := range group {
+				if results[i].err == nil || targets[i].retry.Attempts <= 1 {
+					release()
+					continue
+				}
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					defer release()
+					results[i] = retryUpload(ctx, reopen, targets[i], results[i], expected)
+				}(i)
+			}
+		}(group, reader)
+		reader = nil
+	}
+	wg.Wait()
+
+	return results
+}
+
+// retryUpload retries a failed upload following the retry policy of the target
+func retryUpload(ctx context.Context, reopen func() (io.ReadCloser, error), target uploadTarget, first transferResult, expected *checksums) transferResult {
+	result := first
+	start := time.Now()
+	clients.Retry(ctx, &target.retry, func(attempt int) error {
+		if attempt == 1 {
+			return first.err
+		}
+		reader, err := reopen()
+		if err != nil {
+			result = transferResult{err: err}
+			return err
+		}
+		defer reader.Close()
+		result = streamToTargets(ctx, reader, []uploadTarget{target}, expected)[0]
+		return result.err
+	})
+	result.duration = first.duration + time.Since(start)
+	return result
+}
+
+// streamToTargets uploads the content of reader to all targets concurrently,
+// reading the source only once and without storing it on disk.
+// If any target has checksum options, the checksums are computed while
+// reading, and the uploads that verify them fail before completing when they
+// don't match the expected ones.
+// Returns the result of the upload to each target
+func streamToTargets(ctx context.Context, reader io.Reader, targets []uploadTarget, expected *checksums) []transferResult {
+	results := make([]transferResult, len(targets))
+	writers := make([]*io.PipeWriter, len(targets))
+
+	var h *hasher
+	hashed, crc32c := false, false
+	for _, target := range targets {
+		if target.checksum != nil {
+			hashed = true
+			crc32c = crc32c || target.checksum.CRC32C
+		}
+	}
+	if hashed {
+		h = newHasher(crc32c)
+		reader = io.TeeReader(reader, h)
+	}
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		wg.Add(1)
+		go func(i int, target uploadTarget, pr *io.PipeReader) {
+			defer wg.Done()
+			putCtx, cancel := target.context(ctx)
+			defer cancel()
+			// Stop reading the stream when the upload times out
+			go func() {
+				<-putCtx.Done()
+				pr.CloseWithError(putCtx.Err())
+			}()
+			cr := &countingReader{reader: pr, size: target.size}
+			start := time.Now()
+			err := target.client.Put(putCtx, cr, target.path, target.metadata)
+			results[i] = transferResult{bytes: cr.count, err: err, duration: time.Since(start)}
+			// Unblock the writer if the upload ended before reading the whole stream
+			pr.CloseWithError(errUploadFinished)
+		}(i, target, pr)
+	}
+
+	fw := &fanoutWriter{
+		writers: writers,
+		failed:  make([]bool, len(writers)),
+	}
+	_, err := io.Copy(fw, reader)
+	var sums *checksums
+	var mismatch error
+	if err == nil && h != nil {
+		sums = h.sums()
+		if expected != nil {
+			mismatch = expected.verify(sums)
+		}
+	}
+	// Propagate the reading errors to the uploads (nil closes with EOF)
+	for i, pw := range writers {
+		if err == nil && mismatch != nil && targets[i].checksum != nil && targets[i].checksum.Verify {
+			pw.CloseWithError(mismatch)
+			continue
+		}
+		pw.CloseWithError(err)
+	}
+	wg.Wait()
+
+	if sums == nil {
+		return results
+	}
+	for i := range targets {
+		target := &targets[i]
+		if results[i].err != nil || target.checksum == nil {
+			continue
+		}
+		results[i].sums = sums
+		if target.checksum.Store == "" {
+			continue
+		}
+		// The checksums of a corrupted file would hide the corruption
+		if mismatch != nil {
+			logging.FromContext(ctx).Warn("The checksums of the uploaded file won't be stored", logging.Fields{"provider": target.provider, "output": target.path, "error": mismatch})
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target *uploadTarget) {
+			defer wg.Done()
+			storeCtx, cancel := target.context(ctx)
+			defer cancel()
+			start := time.Now()
+			results[i].err = storeChecksums(storeCtx, target, sums)
+			results[i].duration += time.Since(start)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
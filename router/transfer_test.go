@@ -0,0 +1,324 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	"handler/function/clients"
+	"handler/function/config"
+)
+
+// fakeClient struct to represent an in-memory storage client
+type fakeClient struct {
+	mu      sync.Mutex
+	files   map[string]string
+	putErr  error
+	readMax int64
+	// block makes Put and Copy wait until the context is done
+	block bool
+	// failures is the number of uploads that fail with putErr before
+	// succeeding (0 means that all of them fail)
+	failures int
+	puts     int
+	// active and maxActive count the concurrent uploads
+	active    int
+	maxActive int
+	gets      int
+	// metadata of the written files
+	metadata map[string]*clients.Metadata
+	// sizes reported by the readers of the written files
+	sizes map[string]int64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		files:    make(map[string]string),
+		metadata: make(map[string]*clients.Metadata),
+		sizes:    make(map[string]int64),
+	}
+}
+
+func (fc *fakeClient) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.gets++
+	content, ok := fc.files[path]
+	if !ok {
+		return nil, errors.New("File not found")
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fc *fakeClient) Put(ctx context.Context, reader io.Reader, path string, metadata *clients.Metadata) error {
+	fc.mu.Lock()
+	fc.active++
+	if fc.active > fc.maxActive {
+		fc.maxActive = fc.active
+	}
+	fc.mu.Unlock()
+	defer func() {
+		fc.mu.Lock()
+		fc.active--
+		fc.mu.Unlock()
+	}()
+
+	if fc.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	body := reader
+	if fc.readMax > 0 {
+		body = io.LimitReader(reader, fc.readMax)
+	}
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.puts++
+	if fc.putErr != nil && (fc.failures == 0 || fc.puts <= fc.failures) {
+		return fc.putErr
+	}
+	fc.files[path] = string(content)
+	fc.metadata[path] = metadata
+	if sizer, ok := reader.(clients.Sizer); ok {
+		fc.sizes[path] = sizer.Size()
+	}
+	return nil
+}
+
+func (fc *fakeClient) Delete(ctx context.Context, path string) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	delete(fc.files, path)
+	return nil
+}
+
+func (fc *fakeClient) Copy(ctx context.Context, srcPath, dstPath string, metadata *clients.Metadata) error {
+	if fc.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.files[dstPath] = fc.files[srcPath]
+	if metadata == nil {
+		metadata = fc.metadata[srcPath]
+	}
+	fc.metadata[dstPath] = metadata
+	return nil
+}
+
+func TestTransferToTargets(t *testing.T) {
+	src := newFakeClient()
+	src.files["input/file"] = "content"
+	dst := newFakeClient()
+
+	targets := []uploadTarget{
+		{provider: "src", path: "copy/file", client: src, copier: src},
+	}
+	for _, p := range []string{"a/file", "b/file", "c/file", "d/file", "e/file"} {
+		targets = append(targets, uploadTarget{provider: "dst", path: p, client: dst, size: int64(len("content"))})
+	}
+
+	reader, _ := src.Get(context.Background(), "input/file")
+	reopen := func() (io.ReadCloser, error) {
+		return src.Get(context.Background(), "input/file")
+	}
+	results := transferToTargets(context.Background(), reader, reopen, "input/file", targets, 2, nil)
+
+	for i, result := range results {
+		if result.err != nil {
+			t.Errorf("Error transferring file to target %d: %v", i, result.err)
+		}
+		if i > 0 && result.bytes != int64(len("content")) {
+			t.Errorf("Error counting the bytes transferred to target %d", i)
+		}
+	}
+	if src.files["copy/file"] != "content" {
+		t.Error("Error copying file server-side")
+	}
+	for _, p := range []string{"a/file", "b/file", "c/file", "d/file", "e/file"} {
+		if dst.files[p] != "content" {
+			t.Errorf("Error uploading file to '%s'", p)
+		}
+		if dst.sizes[p] != int64(len("content")) {
+			t.Errorf("Error passing the size of the file uploaded to '%s'", p)
+		}
+	}
+	if dst.maxActive > 2 {
+		t.Error("Error limiting the number of concurrent uploads")
+	}
+	// The first group of streamed targets uses the reader passed, the
+	// others reopen the source. Groups have at most 2 targets
+	if src.gets < 3 || src.gets > 5 {
+		t.Errorf("Error reopening the source: %d reads", src.gets)
+	}
+}
+
+func TestTransferToTargetsSlots(t *testing.T) {
+	src := newFakeClient()
+	src.files["input/file"] = "content"
+	blocked := newFakeClient()
+	blocked.block = true
+	dst := newFakeClient()
+
+	// The slow copies only hold their own slot, so the other uploads don't
+	// wait for them
+	timeout := 200 * time.Millisecond
+	targets := []uploadTarget{
+		{provider: "blocked", path: "copy/a", client: blocked, copier: blocked, timeout: timeout},
+		{provider: "dst", path: "a/file", client: dst},
+		{provider: "dst", path: "b/file", client: dst},
+		{provider: "blocked", path: "copy/b", client: blocked, copier: blocked, timeout: timeout},
+	}
+	reopen := func() (io.ReadCloser, error) {
+		return src.Get(context.Background(), "input/file")
+	}
+	start := time.Now()
+	results := transferToTargets(context.Background(), nil, reopen, "input/file", targets, 2, nil)
+	if elapsed := time.Since(start); elapsed >= 2*timeout {
+		t.Errorf("Error freeing the slots of finished uploads: %v elapsed", elapsed)
+	}
+	if results[0].err != context.DeadlineExceeded || results[3].err != context.DeadlineExceeded {
+		t.Error("Error applying the copy timeout")
+	}
+	if results[1].err != nil || results[2].err != nil || dst.files["a/file"] != "content" || dst.files["b/file"] != "content" {
+		t.Error("Error uploading files along slow copies")
+	}
+}
+
+func TestTransferToTargetsTimeout(t *testing.T) {
+	blocked := newFakeClient()
+	blocked.block = true
+	ok := newFakeClient()
+
+	targets := []uploadTarget{
+		{provider: "blocked", path: "bucket/file", client: blocked, timeout: 10 * time.Millisecond},
+		{provider: "ok", path: "bucket/file", client: ok},
+	}
+	reader := ioutil.NopCloser(strings.NewReader("content"))
+	results := transferToTargets(context.Background(), reader, nil, "input/file", targets, 0, nil)
+
+	if results[0].err != context.DeadlineExceeded {
+		t.Error("Error applying the upload timeout")
+	}
+	if results[1].err != nil || ok.files["bucket/file"] != "content" {
+		t.Error("Error uploading file along a timed out upload")
+	}
+}
+
+func TestTransferToTargetsRetry(t *testing.T) {
+	src := newFakeClient()
+	src.files["input/file"] = "content"
+	flaky := newFakeClient()
+	flaky.putErr = awserr.NewRequestFailure(awserr.New("InternalError", "error", nil), 500, "id")
+	flaky.failures = 2
+	broken := newFakeClient()
+	broken.putErr = flaky.putErr
+
+	retry := config.RetryPolicy{Attempts: 3, InitialBackoff: 1}
+	targets := []uploadTarget{
+		{provider: "flaky", path: "bucket/file", client: flaky, retry: retry},
+		{provider: "broken", path: "bucket/file", client: broken, retry: retry},
+	}
+	reader, _ := src.Get(context.Background(), "input/file")
+	reopen := func() (io.ReadCloser, error) {
+		return src.Get(context.Background(), "input/file")
+	}
+	results := transferToTargets(context.Background(), reader, reopen, "input/file", targets, 0, nil)
+
+	if results[0].err != nil || flaky.files["bucket/file"] != "content" {
+		t.Error("Error retrying failed upload")
+	}
+	if results[1].err == nil {
+		t.Error("Error reporting upload failed after retries")
+	}
+	// One read for the first attempt and two more for each target
+	if src.gets != 5 {
+		t.Errorf("Error reopening the source to retry uploads: %d reads", src.gets)
+	}
+}
+
+func TestStreamToTargets(t *testing.T) {
+	content := strings.Repeat("0123456789", 100000)
+	ok1 := newFakeClient()
+	ok2 := newFakeClient()
+	failing := newFakeClient()
+	failing.putErr = errors.New("Upload error")
+	failing.readMax = 10
+
+	targets := []uploadTarget{
+		{provider: "ok1", path: "bucket/file", client: ok1},
+		{provider: "failing", path: "bucket/file", client: failing},
+		{provider: "ok2", path: "other/file", client: ok2},
+	}
+	results := streamToTargets(context.Background(), bytes.NewBufferString(content), targets, nil)
+
+	if results[0].err != nil || results[2].err != nil {
+		t.Error("Error streaming file to targets")
+	}
+	if results[1].err == nil {
+		t.Error("Error reporting failed target")
+	}
+	if ok1.files["bucket/file"] != content || ok2.files["other/file"] != content {
+		t.Error("Error streaming the whole file to targets")
+	}
+}
+
+// errReader returns an error after reading some bytes
+type errReader struct {
+	read bool
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	if er.read {
+		return 0, errors.New("Read error")
+	}
+	er.read = true
+	return copy(p, "partial"), nil
+}
+
+func TestStreamToTargetsReadError(t *testing.T) {
+	client := newFakeClient()
+	results := streamToTargets(context.Background(), &errReader{}, []uploadTarget{
+		{provider: "client", path: "bucket/file", client: client},
+	}, nil)
+	if results[0].err == nil {
+		t.Error("Error propagating read errors to targets")
+	}
+	if _, ok := client.files["bucket/file"]; ok {
+		t.Error("Error uploading partial file")
+	}
+}
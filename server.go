@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	//"github.com/grycap/multi-out-faas/clients"
+	//"github.com/grycap/multi-out-faas/config"
+	//"github.com/grycap/multi-out-faas/metrics"
+	"handler/function/clients"
+	"handler/function/config"
+	"handler/function/metrics"
+)
+
+// readinessTimeout is the maximum duration of the connectivity checks
+const readinessTimeout = 5 * time.Second
+
+// Readiness status values
+const (
+	statusReady   = "ready"
+	statusUnready = "unready"
+)
+
+// readiness struct to represent the result of the readiness checks
+type readiness struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Connectivity errors of each storage provider ("ok" if reachable)
+	Providers map[string]string `json:"providers"`
+}
+
+// NewServer returns an HTTP server that routes the events received in "/"
+// with the config file in configPath, so the function can run outside
+// OpenFaaS (e.g. receiving MinIO webhooks directly). The server exposes the
+// "/healthz" and "/readyz" endpoints, the latter checking the connectivity
+// with the storage providers, and the Prometheus metrics in "/metrics"
+func NewServer(addr, configPath string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(r.Context(), w, http.StatusMethodNotAllowed, "Events must be sent with a POST request")
+			return
+		}
+		handleEvent(w, r, configPath)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		res := checkReadiness(r.Context(), configPath)
+		statusCode := http.StatusOK
+		if res.Status != statusReady {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeResponse(r.Context(), w, statusCode, res)
+	})
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+// checkReadiness validates the config file and checks the connectivity with
+// all the storage providers concurrently
+func checkReadiness(ctx context.Context, configPath string) *readiness {
+	res := &readiness{
+		Status:    statusReady,
+		Providers: make(map[string]string),
+	}
+	cfg, _, err := cachedConfig.load(configPath)
+	if err != nil {
+		res.Status = statusUnready
+		res.Error = err.Error()
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, provider := range cfg.StorageProviders {
+		wg.Add(1)
+		go func(name string, provider config.StorageProvider) {
+			defer wg.Done()
+			result := "ok"
+			if err := clients.Ping(ctx, &provider); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res.Providers[name] = result
+			if result != "ok" {
+				res.Status = statusUnready
+			}
+		}(name, provider)
+	}
+	wg.Wait()
+	return res
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) GRyCAP - I3M - UPV
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package function
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	//"github.com/grycap/multi-out-faas/router"
+	"handler/function/router"
+)
+
+func TestServer(t *testing.T) {
+	dir, cleanup := newTestLocalSetup(t)
+	defer cleanup()
+	server := NewServer(":0", filepath.Join(dir, "secrets", "config"))
+
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Error checking health: %d", w.Code)
+	}
+
+	checkReadiness := func(expectedCode int) *readiness {
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var res readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != expectedCode {
+			t.Errorf("Error checking readiness: %d %+v", w.Code, res)
+		}
+		return &res
+	}
+	if res := checkReadiness(http.StatusOK); res.Providers["input"] != "ok" || res.Providers["output"] != "ok" {
+		t.Errorf("Error checking readiness: %+v", res)
+	}
+
+	// Route an event
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Records":[{"eventSource":"local", "path":"videos/video.mp4"}]}`)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), router.StatusUnmatched) {
+		t.Errorf("Error routing event: %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `multi_out_faas_records_total{source="local",status="unmatched"}`) {
+		t.Errorf("Error exposing metrics:\n%s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Error rejecting GET request: %d", w.Code)
+	}
+
+	// Unmounted directories are reported
+	if err := os.RemoveAll(filepath.Join(dir, "output")); err != nil {
+		t.Fatal(err)
+	}
+	if res := checkReadiness(http.StatusServiceUnavailable); res.Providers["input"] != "ok" || res.Providers["output"] == "ok" {
+		t.Errorf("Error reporting unreachable provider: %+v", res)
+	}
+
+	// Invalid config files are reported
+	server = NewServer(":0", filepath.Join(dir, "missing"))
+	if res := checkReadiness(http.StatusServiceUnavailable); res.Error == "" {
+		t.Errorf("Error reporting missing config file: %+v", res)
+	}
+}